@@ -164,7 +164,7 @@ func TestPublishIntegration(t *testing.T) {
 			b, err := loader.LoadDataAsBytes(filepath.Join("../testdata/intake-v2/", tc.payload))
 			require.NoError(t, err)
 			docs := testPublishIntake(t, apm, events, bytes.NewReader(b))
-			approvals.AssertApproveResult(t, "test_approved_es_documents/TestPublishIntegration"+tc.name, docs)
+			approvals.AssertApproveResult(t, "test_approved_es_documents/TestPublishIntegration"+tc.name, docs, "ingested")
 		})
 	}
 }
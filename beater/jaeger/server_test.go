@@ -71,7 +71,7 @@ func TestApprovals(t *testing.T) {
 			require.NoError(t, json.Unmarshal(data, &request))
 
 			require.NoError(t, tc.sendBatchGRPC(request.Batch))
-			require.NoError(t, approvals.ApproveEvents(tc.events, f))
+			require.NoError(t, approvals.ApproveEvents(tc.events, f, "ingested"))
 
 			tc.events = nil
 			thriftBatch := &jaegerthrift.Batch{
@@ -79,7 +79,7 @@ func TestApprovals(t *testing.T) {
 				Spans:   jaegerthriftconv.FromDomain(request.Batch.Spans),
 			}
 			require.NoError(t, tc.sendBatchHTTP(thriftBatch))
-			require.NoError(t, approvals.ApproveEvents(tc.events, f))
+			require.NoError(t, approvals.ApproveEvents(tc.events, f, "ingested"))
 		})
 	}
 }
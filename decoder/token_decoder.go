@@ -0,0 +1,128 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decoder
+
+import (
+	"encoding/json"
+)
+
+// DecodeObject reads the next JSON value from d. If it is an object, fn is
+// called with each of its keys in turn, and must consume the key's value
+// from d, e.g. with d.Decode or SkipValue; DecodeObject then returns true.
+// Any other value is decoded as by d.Decode into an interface{}, and returned.
+func DecodeObject(d *json.Decoder, fn func(key string) error) (interface{}, bool, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return nil, false, err
+	}
+	if tok != json.Delim('{') {
+		value, err := decodeRest(d, tok)
+		return value, false, err
+	}
+	for d.More() {
+		key, err := d.Token()
+		if err != nil {
+			return nil, false, err
+		}
+		if err := fn(key.(string)); err != nil {
+			return nil, false, err
+		}
+	}
+	if _, err := d.Token(); err != nil {
+		return nil, false, err
+	}
+	return nil, true, nil
+}
+
+// DecodeArray reads the next JSON value from d. If it is an array, fn is
+// called for each of its elements in turn, and must consume the element
+// from d, e.g. with d.Decode or SkipValue; DecodeArray then returns true.
+// Any other value is decoded as by d.Decode into an interface{}, and returned.
+func DecodeArray(d *json.Decoder, fn func() error) (interface{}, bool, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return nil, false, err
+	}
+	if tok != json.Delim('[') {
+		value, err := decodeRest(d, tok)
+		return value, false, err
+	}
+	for d.More() {
+		if err := fn(); err != nil {
+			return nil, false, err
+		}
+	}
+	if _, err := d.Token(); err != nil {
+		return nil, false, err
+	}
+	return nil, true, nil
+}
+
+// SkipValue reads and discards the next JSON value from d, without
+// decoding it.
+func SkipValue(d *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// decodeRest decodes the remainder of the JSON value starting with tok.
+func decodeRest(d *json.Decoder, tok json.Token) (interface{}, error) {
+	switch tok {
+	case json.Delim('{'):
+		m := make(map[string]interface{})
+		for d.More() {
+			key, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			var value interface{}
+			if err := d.Decode(&value); err != nil {
+				return nil, err
+			}
+			m[key.(string)] = value
+		}
+		_, err := d.Token()
+		return m, err
+	case json.Delim('['):
+		arr := []interface{}{}
+		for d.More() {
+			var value interface{}
+			if err := d.Decode(&value); err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err := d.Token()
+		return arr, err
+	}
+	return tok, nil
+}
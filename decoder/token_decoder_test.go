@@ -0,0 +1,101 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decoder_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/apm-server/decoder"
+)
+
+func TestDecodeObject(t *testing.T) {
+	d := decoder.NewJSONDecoder(strings.NewReader(`{"a":1,"skip":{"b":[1,{"c":null}]},"d":"x"}`))
+	decoded := make(map[string]interface{})
+	value, ok, err := decoder.DecodeObject(d, func(key string) error {
+		if key == "skip" {
+			return decoder.SkipValue(d)
+		}
+		var v interface{}
+		err := d.Decode(&v)
+		decoded[key] = v
+		return err
+	})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, value)
+	assert.Equal(t, map[string]interface{}{"a": json.Number("1"), "d": "x"}, decoded)
+}
+
+func TestDecodeArray(t *testing.T) {
+	d := decoder.NewJSONDecoder(strings.NewReader(`["a",["b"],{"c":1},"d"]`))
+	var decoded []interface{}
+	value, ok, err := decoder.DecodeArray(d, func() error {
+		var v interface{}
+		err := d.Decode(&v)
+		decoded = append(decoded, v)
+		return err
+	})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, value)
+	assert.Equal(t, []interface{}{
+		"a", []interface{}{"b"}, map[string]interface{}{"c": json.Number("1")}, "d",
+	}, decoded)
+}
+
+func TestDecodeObjectArrayOtherValues(t *testing.T) {
+	for _, input := range []string{
+		`null`, `true`, `1.5`, `"x"`, `[]`, `[1,{"a":[2]}]`, `{}`, `{"a":{"b":[null]}}`,
+	} {
+		t.Run(input, func(t *testing.T) {
+			expected, err := decoder.DecodeJSONData(strings.NewReader(`{"v":` + input + `}`))
+			require.NoError(t, err)
+
+			fail := func() error { return assert.AnError }
+			if !strings.HasPrefix(input, "{") {
+				value, ok, err := decoder.DecodeObject(decoder.NewJSONDecoder(strings.NewReader(input)),
+					func(string) error { return fail() })
+				require.NoError(t, err)
+				assert.False(t, ok)
+				assert.Equal(t, expected["v"], value)
+			}
+			if !strings.HasPrefix(input, "[") {
+				value, ok, err := decoder.DecodeArray(decoder.NewJSONDecoder(strings.NewReader(input)), fail)
+				require.NoError(t, err)
+				assert.False(t, ok)
+				assert.Equal(t, expected["v"], value)
+			}
+		})
+	}
+}
+
+func TestDecodeObjectInvalid(t *testing.T) {
+	for _, input := range []string{``, `{"a":`, `{"a":1`, `[1,`, `{"a":[}`} {
+		d := decoder.NewJSONDecoder(strings.NewReader(input))
+		_, _, err := decoder.DecodeObject(d, func(string) error {
+			var v interface{}
+			return d.Decode(&v)
+		})
+		assert.Error(t, err, input)
+	}
+}
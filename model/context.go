@@ -18,11 +18,17 @@
 package model
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/elastic/apm-server/model/field"
 
@@ -86,6 +92,10 @@ type Req struct {
 	Env     interface{}
 	Socket  *Socket
 	Cookies interface{}
+
+	// BodyTruncated records whether Body was truncated to the
+	// configured maximum size.
+	BodyTruncated bool
 }
 
 // Socket indicates whether an http request was encrypted and the initializers remote address
@@ -112,6 +122,54 @@ type MinimalResp struct {
 // Client holds information about the client.ip of the event.
 type Client struct {
 	IP net.IP
+
+	// NATIP holds the socket IP of a proxied client, if known.
+	NATIP net.IP
+
+	// Geo holds geographic information resolved for IP, if any.
+	Geo *Geo
+}
+
+// Geo holds geographic information about an IP address.
+type Geo struct {
+	CountryISOCode string
+	CityName       string
+
+	// Location holds the coordinates, if known.
+	Location *GeoLocation
+}
+
+// GeoLocation holds geographic coordinates.
+type GeoLocation struct {
+	Lat float64
+	Lon float64
+}
+
+// GeoResolver resolves IP addresses to geographic information,
+// e.g. using a GeoIP database.
+type GeoResolver interface {
+	// Resolve returns geographic information for ip,
+	// or nil if none is known.
+	Resolve(ip net.IP) *Geo
+}
+
+// filterExperimental returns a copy of experimental holding only the
+// given top-level keys, or nil if none of them are present.
+func filterExperimental(experimental interface{}, keys []string) interface{} {
+	m, ok := experimental.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	filtered := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		if v, ok := m[k]; ok {
+			filtered[k] = v
+		}
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+	return filtered
 }
 
 // DecodeContext parses all information from input, nested under key context and returns an instance of Context.
@@ -137,17 +195,20 @@ func DecodeContext(input interface{}, cfg Config, err error) (*Context, error) {
 	var experimental interface{}
 	if cfg.Experimental {
 		experimental = decoder.Interface(ctxInp, "experimental")
+		if len(cfg.ExperimentalKeys) > 0 {
+			experimental = filterExperimental(experimental, cfg.ExperimentalKeys)
+		}
 	}
 	http, err := decodeHTTP(ctxInp, cfg.HasShortFieldNames, decoder.Err)
 	url, err := decodeUrl(ctxInp, err)
-	labels, err := decodeLabels(ctxInp, cfg.HasShortFieldNames, err)
+	labels, err := decodeLabels(ctxInp, cfg.HasShortFieldNames, cfg.AcceptArrayTags, err)
 	custom, err := decodeCustom(ctxInp, cfg.HasShortFieldNames, err)
 	page, err := decodePage(ctxInp, cfg.HasShortFieldNames, err)
 	service, err := metadata.DecodeService(serviceInp, cfg.HasShortFieldNames, err)
 	user, err := metadata.DecodeUser(userInp, cfg.HasShortFieldNames, err)
 	user = addUserAgent(user, http)
-	client, err := decodeClient(user, http, err)
-	message, err := DecodeMessage(ctxInp, err)
+	client, err := decodeClient(user, http, cfg.EmitSourceNAT, err)
+	message, err := DecodeMessage(ctxInp, cfg.CanonicalizeMessageHeaders, err)
 
 	ctx := Context{
 		Http:         http,
@@ -183,6 +244,20 @@ func (url *Url) Fields() common.MapStr {
 	return fields
 }
 
+// Extension returns the file extension of the last path segment, without
+// the leading dot, e.g. "js" for "/static/app.min.js". It returns nil if
+// the url has no path or the last segment has no extension.
+func (url *Url) Extension() *string {
+	if url == nil || url.Path == nil {
+		return nil
+	}
+	ext := strings.TrimPrefix(path.Ext(*url.Path), ".")
+	if ext == "" {
+		return nil
+	}
+	return &ext
+}
+
 // Fields returns common.MapStr holding transformed data for attribute http.
 func (h *Http) Fields() common.MapStr {
 	if h == nil {
@@ -216,6 +291,37 @@ func (page *Page) Fields() common.MapStr {
 	return fields
 }
 
+// Domain returns the host name of the page URL, or nil if it has none.
+func (page *Page) Domain() *string {
+	if page == nil || page.Url == nil {
+		return nil
+	}
+	u, err := url.Parse(*page.Url)
+	if err != nil || u.Hostname() == "" {
+		return nil
+	}
+	domain := u.Hostname()
+	return &domain
+}
+
+// RefererDomain returns the host name of the page referer, or nil if it
+// has none. Referers consisting of a bare domain are accepted.
+func (page *Page) RefererDomain() *string {
+	if page == nil || page.Referer == nil || *page.Referer == "" {
+		return nil
+	}
+	u, err := url.Parse(*page.Referer)
+	if err == nil && u.Host == "" && !strings.Contains(*page.Referer, "://") {
+		// no scheme, e.g. "example.com/path"
+		u, err = url.Parse("//" + *page.Referer)
+	}
+	if err != nil || u.Hostname() == "" {
+		return nil
+	}
+	domain := u.Hostname()
+	return &domain
+}
+
 // Fields returns common.MapStr holding transformed data for attribute label.
 func (labels *Labels) Fields() common.MapStr {
 	if labels == nil {
@@ -224,6 +330,20 @@ func (labels *Labels) Fields() common.MapStr {
 	return common.MapStr(*labels)
 }
 
+// StringLabels returns a copy of labels, with boolean and numeric values
+// formatted as strings. The given labels are left unmodified, as they may
+// be shared with the metadata.
+func StringLabels(labels common.MapStr) common.MapStr {
+	stringified := make(common.MapStr, len(labels))
+	for k, v := range labels {
+		if _, ok := v.(string); !ok && v != nil {
+			v = fmt.Sprint(v)
+		}
+		stringified[k] = v
+	}
+	return stringified
+}
+
 // Fields returns common.MapStr holding transformed data for attribute custom.
 func (custom *Custom) Fields() common.MapStr {
 	if custom == nil {
@@ -237,7 +357,58 @@ func (c *Client) Fields() common.MapStr {
 	if c == nil || c.IP == nil {
 		return nil
 	}
-	return common.MapStr{"ip": c.IP.String()}
+	fields := common.MapStr{"ip": c.IP.String()}
+	if c.Geo != nil {
+		geo := common.MapStr{}
+		if c.Geo.CountryISOCode != "" {
+			geo["country_iso_code"] = c.Geo.CountryISOCode
+		}
+		if c.Geo.CityName != "" {
+			geo["city_name"] = c.Geo.CityName
+		}
+		if c.Geo.Location != nil {
+			geo["location"] = common.MapStr{"lat": c.Geo.Location.Lat, "lon": c.Geo.Location.Lon}
+		}
+		utility.Set(fields, "geo", geo)
+	}
+	return fields
+}
+
+// ResolveGeo returns a copy of c with Geo resolved for its IP using resolver,
+// or lookup if resolver is nil. If resolver is set, lookup is not consulted,
+// even when the resolver knows nothing about the IP. c itself is returned
+// if no geographic information is found.
+func (c *Client) ResolveGeo(resolver GeoResolver, lookup func(net.IP) (country, city string)) *Client {
+	if c == nil || c.IP == nil {
+		return c
+	}
+	var geo *Geo
+	if resolver != nil {
+		geo = resolver.Resolve(c.IP)
+	} else if lookup != nil {
+		if country, city := lookup(c.IP); country != "" || city != "" {
+			geo = &Geo{CountryISOCode: country, CityName: city}
+		}
+	}
+	if geo == nil {
+		return c
+	}
+	resolved := *c
+	resolved.Geo = geo
+	return &resolved
+}
+
+// SourceFields returns common.MapStr holding transformed data for attribute source,
+// which holds the client IP along with the NAT IP of proxied clients.
+func (c *Client) SourceFields() common.MapStr {
+	if c == nil || c.IP == nil {
+		return nil
+	}
+	fields := common.MapStr{"ip": c.IP.String()}
+	if c.NATIP != nil {
+		fields["nat"] = common.MapStr{"ip": c.NATIP.String()}
+	}
+	return fields
 }
 
 func addUserAgent(user *metadata.User, h *Http) *metadata.User {
@@ -267,10 +438,24 @@ func decodeUrl(raw common.MapStr, err error) (*Url, error) {
 		Full:     decoder.StringPtr(inpUrl, "full"),
 		Domain:   decoder.StringPtr(inpUrl, "hostname"),
 		Path:     decoder.StringPtr(inpUrl, "pathname"),
-		Query:    decoder.StringPtr(inpUrl, "search"),
+		Query:    decoder.StringPtr(inpUrl, "query"),
 		Fragment: decoder.StringPtr(inpUrl, "hash"),
 	}
-	if scheme := decoder.StringPtr(inpUrl, "protocol"); scheme != nil {
+	// agents may send the query string as "search" instead, following
+	// the browser Location API including a leading question mark
+	if url.Query == nil {
+		if search := decoder.StringPtr(inpUrl, "search"); search != nil {
+			query := strings.TrimPrefix(*search, "?")
+			url.Query = &query
+		}
+	}
+	// agents may send the URL scheme as either "scheme" or "protocol",
+	// the latter following the browser Location API including a trailing colon
+	scheme := decoder.StringPtr(inpUrl, "scheme")
+	if scheme == nil {
+		scheme = decoder.StringPtr(inpUrl, "protocol")
+	}
+	if scheme != nil {
 		trimmed := strings.TrimSuffix(*scheme, ":")
 		url.Scheme = &trimmed
 	}
@@ -287,7 +472,7 @@ func decodeUrl(raw common.MapStr, err error) (*Url, error) {
 	return &url, err
 }
 
-func decodeClient(user *metadata.User, http *Http, err error) (*Client, error) {
+func decodeClient(user *metadata.User, http *Http, withNAT bool, err error) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
@@ -298,10 +483,18 @@ func decodeClient(user *metadata.User, http *Http, err error) (*Client, error) {
 	// http.Request.Headers and http.Request.Socket information is only set for backend events
 	// try to first extract an IP address from the headers, if not possible use IP address from socket remote_address
 	if http != nil && http.Request != nil {
+		hasSocket := http.Request.Socket != nil && http.Request.Socket.RemoteAddress != nil
 		if ip := utility.ExtractIPFromHeader(http.Request.Headers); ip != nil {
-			return &Client{IP: ip}, nil
+			client := Client{IP: ip}
+			if withNAT && hasSocket {
+				// the request was proxied, keep track of the proxy's IP
+				if socketIP := utility.ParseIP(*http.Request.Socket.RemoteAddress); socketIP != nil && !socketIP.Equal(ip) {
+					client.NATIP = socketIP
+				}
+			}
+			return &client, nil
 		}
-		if http.Request.Socket != nil && http.Request.Socket.RemoteAddress != nil {
+		if hasSocket {
 			return &Client{IP: utility.ParseIP(*http.Request.Socket.RemoteAddress)}, nil
 		}
 	}
@@ -390,11 +583,17 @@ func decodePage(raw common.MapStr, hasShortFieldNames bool, err error) (*Page, e
 	}, decoder.Err
 }
 
-func decodeLabels(raw common.MapStr, hasShortFieldNames bool, err error) (*Labels, error) {
+func decodeLabels(raw common.MapStr, hasShortFieldNames, acceptArray bool, err error) (*Labels, error) {
 	if err != nil {
 		return nil, err
 	}
 	fieldName := field.Mapper(hasShortFieldNames)
+	if arr, ok := raw[fieldName("tags")].([]interface{}); ok {
+		if !acceptArray {
+			return nil, errors.New("invalid tags: array tags not enabled")
+		}
+		return decodeArrayLabels(arr), nil
+	}
 	decoder := utility.ManualDecoder{}
 	if l := decoder.MapStr(raw, fieldName("tags")); decoder.Err == nil && l != nil {
 		labels := Labels(l)
@@ -403,6 +602,36 @@ func decodeLabels(raw common.MapStr, hasShortFieldNames bool, err error) (*Label
 	return nil, decoder.Err
 }
 
+// decodeArrayLabels folds labels sent as an array of objects, e.g.
+// [{"key": "a", "value": "b"}], into Labels. Elements without a string
+// key, with a key containing any of '.', '*' and '"', or with a value that
+// is not a string, number, boolean or null are skipped.
+func decodeArrayLabels(arr []interface{}) *Labels {
+	labels := Labels{}
+	for _, elem := range arr {
+		m, ok := elem.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		key, ok := m["key"].(string)
+		if !ok || key == "" || strings.ContainsAny(key, `.*"`) {
+			continue
+		}
+		value, ok := m["value"]
+		if !ok {
+			continue
+		}
+		switch value.(type) {
+		case nil, string, bool, json.Number, float64:
+			labels[key] = value
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return &labels
+}
+
 func decodeCustom(raw common.MapStr, hasShortFieldNames bool, err error) (*Custom, error) {
 	if err != nil {
 		return nil, err
@@ -416,6 +645,73 @@ func decodeCustom(raw common.MapStr, hasShortFieldNames bool, err error) (*Custo
 	return nil, decoder.Err
 }
 
+// SanitizeKeys returns a copy of custom with dots in keys replaced by
+// underscores, as described for sanitizeKeys.
+func (custom *Custom) SanitizeKeys() *Custom {
+	if custom == nil {
+		return nil
+	}
+	sanitized := Custom(sanitizeKeys(*custom))
+	return &sanitized
+}
+
+// sanitizeKeys returns a copy of m, with dots in keys of m and any nested
+// objects replaced by underscores. A key colliding with an existing key once
+// sanitized gets the first free suffix of "_2", "_3", and so on. Keys without
+// dots keep their name, others are assigned in sorted order, so the result
+// is deterministic.
+func sanitizeKeys(m map[string]interface{}) map[string]interface{} {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sanitized := make(map[string]interface{}, len(m))
+	set := func(k string, v interface{}) {
+		if nested, ok := v.(map[string]interface{}); ok {
+			v = sanitizeKeys(nested)
+		}
+		sanitized[k] = v
+	}
+	for _, k := range keys {
+		if !strings.Contains(k, ".") {
+			set(k, m[k])
+		}
+	}
+	for _, k := range keys {
+		if !strings.Contains(k, ".") {
+			continue
+		}
+		base := strings.ReplaceAll(k, ".", "_")
+		name := base
+		for i := 2; ; i++ {
+			if _, ok := sanitized[name]; !ok {
+				break
+			}
+			name = base + "_" + strconv.Itoa(i)
+		}
+		set(name, m[k])
+	}
+	return sanitized
+}
+
+// TruncateBody truncates a string body to at most max bytes, without
+// splitting multi-byte characters, and records whether it did so in
+// BodyTruncated. Bodies of other types are left as is.
+func (req *Req) TruncateBody(max int) {
+	body, ok := req.Body.(string)
+	if !ok || len(body) <= max {
+		return
+	}
+	i := max
+	for i > 0 && !utf8.RuneStart(body[i]) {
+		i--
+	}
+	req.Body = body[:i]
+	req.BodyTruncated = true
+}
+
 func (req *Req) fields() common.MapStr {
 	if req == nil {
 		return nil
@@ -425,6 +721,9 @@ func (req *Req) fields() common.MapStr {
 	utility.Set(fields, "socket", req.Socket.fields())
 	utility.Set(fields, "env", req.Env)
 	utility.DeepUpdate(fields, "body.original", req.Body)
+	if req.BodyTruncated {
+		utility.DeepUpdate(fields, "body.truncated", true)
+	}
 	utility.Set(fields, "method", req.Method)
 	utility.Set(fields, "cookies", req.Cookies)
 
@@ -477,3 +776,70 @@ func (s *Socket) fields() common.MapStr {
 	utility.Set(fields, "remote_address", s.RemoteAddress)
 	return fields
 }
+
+// EstimatedSize returns a cheap estimate of the serialized size of the
+// HTTP request and response in bytes, without marshaling them.
+func (h *Http) EstimatedSize() int {
+	if h == nil {
+		return 0
+	}
+	size := utility.EstimatedSize(h.Version)
+	if req := h.Request; req != nil {
+		size += utility.EstimatedSize(req.Method, req.Body, req.Headers, req.Env, req.Cookies)
+		if req.Socket != nil {
+			size += utility.EstimatedSize(req.Socket.RemoteAddress, req.Socket.Encrypted)
+		}
+	}
+	if resp := h.Response; resp != nil {
+		size += utility.EstimatedSize(
+			resp.Finished, resp.HeadersSent, resp.StatusCode, resp.Headers,
+			resp.TransferSize, resp.EncodedBodySize, resp.DecodedBodySize,
+		)
+	}
+	return size
+}
+
+// EstimatedSize returns a cheap estimate of the serialized size of the
+// URL in bytes, without marshaling it.
+func (url *Url) EstimatedSize() int {
+	if url == nil {
+		return 0
+	}
+	return utility.EstimatedSize(url.Original, url.Scheme, url.Full, url.Domain, url.Port, url.Path, url.Query, url.Fragment)
+}
+
+// EstimatedSize returns a cheap estimate of the serialized size of the
+// page in bytes, without marshaling it.
+func (page *Page) EstimatedSize() int {
+	if page == nil {
+		return 0
+	}
+	return utility.EstimatedSize(page.Url, page.Referer)
+}
+
+// EstimatedSize returns a cheap estimate of the serialized size of the
+// labels in bytes, without marshaling them.
+func (labels *Labels) EstimatedSize() int {
+	if labels == nil {
+		return 0
+	}
+	return utility.EstimatedSize(common.MapStr(*labels))
+}
+
+// EstimatedSize returns a cheap estimate of the serialized size of the
+// custom context in bytes, without marshaling it.
+func (custom *Custom) EstimatedSize() int {
+	if custom == nil {
+		return 0
+	}
+	return utility.EstimatedSize(common.MapStr(*custom))
+}
+
+// EstimatedSize returns a cheap estimate of the serialized size of the
+// client in bytes, without marshaling it.
+func (c *Client) EstimatedSize() int {
+	if c == nil {
+		return 0
+	}
+	return utility.EstimatedSize(c.IP, c.NATIP)
+}
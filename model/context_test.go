@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,6 +29,7 @@ import (
 
 	"github.com/elastic/beats/v7/libbeat/common"
 
+	"github.com/elastic/apm-server/tests"
 	"github.com/elastic/apm-server/tests/approvals"
 	"github.com/elastic/apm-server/utility"
 )
@@ -90,6 +92,27 @@ func TestDecodeContext(t *testing.T) {
 						"method": "Get",
 						"url":    map[string]interface{}{"raw": "127.0.0.1"}}}},
 		},
+		"url_scheme": {
+			input: map[string]interface{}{
+				"context": map[string]interface{}{
+					"request": map[string]interface{}{
+						"method": "Get",
+						"url":    map[string]interface{}{"raw": "127.0.0.1", "scheme": "https"}}}},
+		},
+		"url_protocol": {
+			input: map[string]interface{}{
+				"context": map[string]interface{}{
+					"request": map[string]interface{}{
+						"method": "Get",
+						"url":    map[string]interface{}{"raw": "127.0.0.1", "protocol": "https"}}}},
+		},
+		"url_protocol_trailing_colon": {
+			input: map[string]interface{}{
+				"context": map[string]interface{}{
+					"request": map[string]interface{}{
+						"method": "Get",
+						"url":    map[string]interface{}{"raw": "127.0.0.1", "protocol": "https:"}}}},
+		},
 		"experimental is not true": {
 			input: map[string]interface{}{"context": map[string]interface{}{
 				"experimental": "experimental data",
@@ -221,3 +244,199 @@ func TestDecodeContext(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeContextArrayTags(t *testing.T) {
+	for name, test := range map[string]struct {
+		tags     interface{}
+		accept   bool
+		expected *Labels
+		err      error
+	}{
+		"array of objects": {
+			tags: []interface{}{
+				map[string]interface{}{"key": "a", "value": "b"},
+				map[string]interface{}{"key": "n", "value": json.Number("1")},
+				map[string]interface{}{"key": "ok", "value": true},
+			},
+			accept:   true,
+			expected: &Labels{"a": "b", "n": json.Number("1"), "ok": true},
+		},
+		"malformed elements skipped": {
+			tags: []interface{}{
+				"a=b",
+				map[string]interface{}{"value": "no key"},
+				map[string]interface{}{"key": "", "value": "empty key"},
+				map[string]interface{}{"key": 1.0, "value": "numeric key"},
+				map[string]interface{}{"key": "a.b", "value": "dotted key"},
+				map[string]interface{}{"key": "a*", "value": "starred key"},
+				map[string]interface{}{"key": "no value"},
+				map[string]interface{}{"key": "obj", "value": map[string]interface{}{"b": "c"}},
+				map[string]interface{}{"key": "a", "value": "b"},
+			},
+			accept:   true,
+			expected: &Labels{"a": "b"},
+		},
+		"all malformed": {
+			tags:   []interface{}{"a=b"},
+			accept: true,
+		},
+		"object": {
+			tags:     map[string]interface{}{"a": "b"},
+			accept:   true,
+			expected: &Labels{"a": "b"},
+		},
+		"array not accepted": {
+			tags: []interface{}{map[string]interface{}{"key": "a", "value": "b"}},
+			err:  errors.New("invalid tags: array tags not enabled"),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"context": map[string]interface{}{"tags": test.tags}}
+			out, err := DecodeContext(input, Config{AcceptArrayTags: test.accept}, nil)
+			assert.Equal(t, test.err, err)
+			if test.err == nil {
+				assert.Equal(t, test.expected, out.Labels)
+			}
+		})
+	}
+}
+
+func TestDecodeContextURLQuery(t *testing.T) {
+	for name, test := range map[string]struct {
+		url      map[string]interface{}
+		expected *string
+	}{
+		"none":              {url: map[string]interface{}{"pathname": "/search"}},
+		"query":             {url: map[string]interface{}{"query": "id=1"}, expected: tests.StringPtr("id=1")},
+		"search":            {url: map[string]interface{}{"search": "id=1"}, expected: tests.StringPtr("id=1")},
+		"search with ?":     {url: map[string]interface{}{"search": "?id=1"}, expected: tests.StringPtr("id=1")},
+		"empty search":      {url: map[string]interface{}{"search": "?"}, expected: tests.StringPtr("")},
+		"query over search": {url: map[string]interface{}{"query": "id=1", "search": "?id=2"}, expected: tests.StringPtr("id=1")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"context": map[string]interface{}{
+				"request": map[string]interface{}{"method": "GET", "url": test.url},
+			}}
+			out, err := DecodeContext(input, Config{}, nil)
+			require.NoError(t, err)
+			require.NotNil(t, out.Url)
+			assert.Equal(t, test.expected, out.Url.Query)
+		})
+	}
+}
+
+func TestReqTruncateBody(t *testing.T) {
+	for name, test := range map[string]struct {
+		body      interface{}
+		max       int
+		expected  interface{}
+		truncated bool
+	}{
+		"under limit":         {body: "abcd", max: 4, expected: "abcd"},
+		"over limit":          {body: "abcdef", max: 4, expected: "abcd", truncated: true},
+		"multi-byte boundary": {body: "ab€d", max: 4, expected: "ab", truncated: true},
+		"object":              {body: map[string]interface{}{"a": "bcdef"}, max: 4, expected: map[string]interface{}{"a": "bcdef"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := Req{Method: "POST", Body: test.body}
+			req.TruncateBody(test.max)
+			assert.Equal(t, test.expected, req.Body)
+			assert.Equal(t, test.truncated, req.BodyTruncated)
+
+			truncated, _ := req.fields().GetValue("body.truncated")
+			if test.truncated {
+				assert.Equal(t, true, truncated)
+			} else {
+				assert.Nil(t, truncated)
+			}
+		})
+	}
+}
+
+func TestDecodeContextKeepsRequestBody(t *testing.T) {
+	body := strings.Repeat("a", 4096)
+	input := map[string]interface{}{"context": map[string]interface{}{
+		"request": map[string]interface{}{"method": "POST", "body": body},
+	}}
+	out, err := DecodeContext(input, Config{MaxRequestBodyBytes: 4}, nil)
+	require.NoError(t, err)
+	require.NotNil(t, out.Http)
+	assert.Equal(t, body, out.Http.Request.Body)
+	assert.False(t, out.Http.Request.BodyTruncated)
+}
+
+func TestDecodeContextExperimentalKeys(t *testing.T) {
+	input := map[string]interface{}{"context": map[string]interface{}{
+		"experimental": map[string]interface{}{"a": "b", "c": map[string]interface{}{"d": 1}, "e": "f"},
+	}}
+	for name, test := range map[string]struct {
+		cfg      Config
+		expected interface{}
+	}{
+		"not experimental": {cfg: Config{ExperimentalKeys: []string{"a"}}},
+		"no keys": {
+			cfg:      Config{Experimental: true},
+			expected: map[string]interface{}{"a": "b", "c": map[string]interface{}{"d": 1}, "e": "f"},
+		},
+		"filtered": {
+			cfg:      Config{Experimental: true, ExperimentalKeys: []string{"a", "c", "x"}},
+			expected: map[string]interface{}{"a": "b", "c": map[string]interface{}{"d": 1}},
+		},
+		"no matching keys": {cfg: Config{Experimental: true, ExperimentalKeys: []string{"x"}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			out, err := DecodeContext(input, test.cfg, nil)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, out.Experimental)
+		})
+	}
+}
+
+func TestCustomSanitizeKeys(t *testing.T) {
+	custom := Custom{
+		"a.b":   "c",
+		"plain": 1.0,
+		"nested": map[string]interface{}{
+			"d.e.f": true,
+		},
+	}
+	expected := Custom{"a_b": "c", "plain": 1.0, "nested": map[string]interface{}{"d_e_f": true}}
+	sanitized := custom.SanitizeKeys()
+	assert.Equal(t, expected, *sanitized)
+	assert.Equal(t, common.MapStr(expected), sanitized.Fields())
+	assert.Contains(t, custom, "a.b", "the original keys are left as is")
+
+	var none *Custom
+	assert.Nil(t, none.SanitizeKeys())
+}
+
+func TestDecodeContextKeepsCustomKeys(t *testing.T) {
+	custom := map[string]interface{}{"a.b": "c"}
+	input := map[string]interface{}{"context": map[string]interface{}{"custom": custom}}
+	out, err := DecodeContext(input, Config{SanitizeCustomKeys: true}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Custom(custom), *out.Custom)
+}
+
+func TestCustomSanitizeKeysCollision(t *testing.T) {
+	custom := Custom{
+		"a_b":   1.0,
+		"a.b":   2.0,
+		"a.b_2": 3.0,
+		"a.b.c": 4.0,
+		"nested": map[string]interface{}{
+			"x.y": "dotted",
+			"x_y": "plain",
+		},
+	}
+	expected := Custom{
+		"a_b":     1.0,
+		"a_b_2":   2.0,
+		"a_b_2_2": 3.0,
+		"a_b_c":   4.0,
+		"nested":  map[string]interface{}{"x_y": "plain", "x_y_2": "dotted"},
+	}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, expected, *custom.SanitizeKeys())
+	}
+}
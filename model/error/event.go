@@ -211,7 +211,7 @@ func (e *Event) Transform(ctx context.Context, tctx *transform.Context) []beat.E
 	utility.Update(fields, "user", e.User.Fields())
 	clientFields := e.Client.Fields()
 	utility.DeepUpdate(fields, "client", clientFields)
-	utility.DeepUpdate(fields, "source", clientFields)
+	utility.DeepUpdate(fields, "source", e.Client.SourceFields())
 	utility.DeepUpdate(fields, "user_agent", e.User.UserAgentFields())
 	utility.DeepUpdate(fields, "service", e.Service.Fields(emptyString, emptyString))
 	utility.DeepUpdate(fields, "agent", e.Service.AgentFields())
@@ -424,6 +424,70 @@ func TestDecodingAnomalies(t *testing.T) {
 	})
 }
 
+func TestErrorEventDecodeKeepsRequestBody(t *testing.T) {
+	body := "a request body longer than the limit"
+	raw := map[string]interface{}{
+		"id":        "id",
+		"exception": map[string]interface{}{"message": "message0", "type": "type0"},
+		"context": map[string]interface{}{
+			"request": map[string]interface{}{"method": "POST", "body": body},
+		},
+	}
+	result, err := DecodeEvent(m.Input{Raw: raw, Config: m.Config{MaxRequestBodyBytes: 4}})
+	require.NoError(t, err)
+	event := result.(*Event)
+	require.NotNil(t, event.Http)
+	assert.Equal(t, body, event.Http.Request.Body)
+	assert.False(t, event.Http.Request.BodyTruncated)
+}
+
+func TestErrorEventDecodeKeepsCustomKeys(t *testing.T) {
+	raw := map[string]interface{}{
+		"id":        "id",
+		"exception": map[string]interface{}{"message": "message0", "type": "type0"},
+		"context":   map[string]interface{}{"custom": map[string]interface{}{"a.b": "c"}},
+	}
+	result, err := DecodeEvent(m.Input{Raw: raw, Config: m.Config{SanitizeCustomKeys: true}})
+	require.NoError(t, err)
+	assert.Equal(t, &m.Custom{"a.b": "c"}, result.(*Event).Custom)
+}
+
+func TestErrorEventTransformSourceNAT(t *testing.T) {
+	for name, test := range map[string]struct {
+		emit   bool
+		source common.MapStr
+	}{
+		"emitted": {
+			emit:   true,
+			source: common.MapStr{"ip": "198.51.100.7", "nat": common.MapStr{"ip": "10.0.0.2"}},
+		},
+		"not emitted": {
+			source: common.MapStr{"ip": "198.51.100.7"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"id":        "id",
+				"exception": map[string]interface{}{"message": "message0", "type": "type0"},
+				"context": map[string]interface{}{
+					"request": map[string]interface{}{
+						"method":  "GET",
+						"headers": map[string]interface{}{"X-Forwarded-For": "198.51.100.7"},
+						"socket":  map[string]interface{}{"remote_address": "10.0.0.2"},
+					},
+				},
+			}
+			transformable, err := DecodeEvent(m.Input{Raw: raw, Config: m.Config{EmitSourceNAT: test.emit}})
+			require.NoError(t, err)
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			assert.Equal(t, test.source, output[0].Fields["source"])
+			assert.Equal(t, common.MapStr{"ip": "198.51.100.7"}, output[0].Fields["client"])
+		})
+	}
+}
+
 func TestEventFields(t *testing.T) {
 	id := "45678"
 	culprit := "some trigger"
@@ -242,7 +242,8 @@ const ModelSchema = `{
     "required": ["url", "method"]
         },
         "tags": {
-                "$id": "doc/spec/tags.json",
+            "anyOf": [
+                {     "$id": "doc/spec/tags.json",
     "title": "Tags",
     "type": ["object", "null"],
     "description": "A flat mapping of user-defined tags with string, boolean or number values.",
@@ -252,7 +253,25 @@ const ModelSchema = `{
             "maxLength": 1024
         }
     },
-    "additionalProperties": false
+    "additionalProperties": false },
+                {     "$id": "doc/spec/tags_array.json",
+    "title": "Tags Array",
+    "type": ["array"],
+    "description": "User-defined tags sent as an array of key/value objects, e.g. [{\"key\": \"a\", \"value\": \"b\"}]. Only accepted if the server is configured to accept array tags. Elements missing a key or value, or with a key containing any of \".\", \"*\" and \"\\\"\", are skipped.",
+    "items": {
+        "type": "object",
+        "properties": {
+            "key": {
+                "type": "string",
+                "maxLength": 1024
+            },
+            "value": {
+                "type": ["string", "boolean", "number", "null"],
+                "maxLength": 1024
+            }
+        }
+    } }
+            ]
         },
         "user": {
             "description": "Describes the correlated user for this event. If user data are provided here, all user related information from metadata is ignored, otherwise the metadata's user information will be stored with the event.",
@@ -274,6 +293,11 @@ const ModelSchema = `{
             "description": "The username of the logged in user",
             "type": ["string", "null"],
             "maxLength": 1024
+        },
+        "domain": {
+            "description": "Domain of the logged in user, e.g. a Windows or Active Directory domain",
+            "type": ["string", "null"],
+            "maxLength": 1024
         }
     }
         },
@@ -395,7 +419,9 @@ const ModelSchema = `{
     "type": ["object", "null"],
     "properties": {
         "queue": {
-            "type": ["object", "null"],
+            "description": "The message queue, either as an object or, for agents sending it flat, as the queue name.",
+            "type": ["object", "string", "null"],
+            "maxLength": 1024,
             "properties": {
                 "name": {
                     "description": "Name of the message queue where the message is received.",
@@ -404,6 +430,16 @@ const ModelSchema = `{
                 }
             }
         },
+        "routing_key": {
+            "description": "Routing key of the message, for brokers that route messages by key, e.g. RabbitMQ.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "exchange": {
+            "description": "Name of the exchange the message is published to, e.g. a RabbitMQ exchange.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
         "age": {
             "type": ["object", "null"],
             "properties": {
@@ -18,9 +18,12 @@
 package model
 
 import (
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/elastic/apm-server/model/metadata"
+	"github.com/elastic/apm-server/utility"
 )
 
 // Input holds the input required for decoding an event.
@@ -48,4 +51,251 @@ type Config struct {
 	Experimental bool
 	// RUM v3 support
 	HasShortFieldNames bool
+
+	// TrimResult controls whether surrounding whitespace is
+	// trimmed from transaction results during decoding.
+	TrimResult bool
+
+	// NormalizeDestinationResource controls whether metricset span
+	// destination resources are lowercased and stripped of any path,
+	// e.g. "MySQL/users" becomes "mysql".
+	NormalizeDestinationResource bool
+
+	// MaxUserNameLength, if positive, is the maximum number of
+	// characters retained for transaction user names.
+	MaxUserNameLength int
+
+	// DropEmptyStrings controls whether optional transaction string
+	// fields holding an empty string are treated as absent.
+	DropEmptyStrings bool
+
+	// ValidateServiceVersion controls whether transactions with a
+	// service version that is not a valid semantic version are rejected.
+	ValidateServiceVersion bool
+
+	// EmitTransactionCategory controls whether a transaction category,
+	// combining the transaction type and result bucket (e.g. "request:2xx"),
+	// is derived during decoding.
+	EmitTransactionCategory bool
+
+	// ValidateUserEmail controls whether transaction user emails failing
+	// a basic address check are dropped during decoding.
+	ValidateUserEmail bool
+
+	// OmitZeroSpanCount controls whether zero transaction span counts
+	// are treated as absent, omitting them from the output.
+	OmitZeroSpanCount bool
+
+	// TypeToCategory maps transaction types to ECS event categories,
+	// emitted as event.category. Unmapped types get no category.
+	TypeToCategory map[string][]string
+
+	// SanitizeCustomKeys controls whether dots in the context.custom keys
+	// of transactions are replaced with underscores, avoiding unintended
+	// nesting. Keys colliding once sanitized are suffixed with "_2", "_3",
+	// and so on. The custom keys of other events are left as is.
+	SanitizeCustomKeys bool
+
+	// StrictIDs controls whether transactions are rejected unless their
+	// trace ID is 32 and their parent ID, if any, 16 lowercase hex
+	// characters.
+	StrictIDs bool
+
+	// MaxEventBytes, if positive, is the maximum size in bytes of an
+	// event's raw JSON encoding. It is only enforced by the stream
+	// processor's reader, against each event line read before the event
+	// is decoded; the metadata line is not checked, and neither are events
+	// passed to DecodeEvent by other means.
+	MaxEventBytes int
+
+	// EmitSourceNAT controls whether the socket IP of a proxied client,
+	// identified by a forwarded IP header, is emitted as source.nat.ip.
+	EmitSourceNAT bool
+
+	// GeoResolver, if non-nil, is used to resolve the client IPs of
+	// transactions to geographic information, emitted under client.geo.
+	// It takes precedence over transform.Context.GeoLookup.
+	GeoResolver GeoResolver
+
+	// EmitECSDuration controls whether transaction durations are also
+	// emitted as the ECS event.duration, in nanoseconds.
+	EmitECSDuration bool
+
+	// EmitHumanDuration controls whether transaction durations are also
+	// emitted as a human readable transaction.duration.human, e.g. "1.67ms".
+	EmitHumanDuration bool
+
+	// SampledAsInt controls whether transaction.sampled is emitted
+	// as an integer, 1 or 0, rather than a boolean.
+	SampledAsInt bool
+
+	// ExperimentalKeys, if non-empty, restricts the context.experimental
+	// object retained in experimental mode to the named top-level keys.
+	ExperimentalKeys []string
+
+	// DropInvalidMetrics controls whether metricset samples with a NaN or
+	// infinite value are dropped, rather than failing the decoding.
+	DropInvalidMetrics bool
+
+	// ExcludeServices holds glob patterns, as matched by path.Match, of
+	// service names whose transactions are dropped during decoding.
+	ExcludeServices []string
+
+	// DimensionLabels names the labels emitted for metricsets under
+	// dimensions rather than labels, e.g. for time series indices.
+	DimensionLabels []string
+
+	// MaxStackTraceIDs, if positive, is the maximum number of transaction
+	// profiler stack trace IDs retained, defaulting to 1000. Any further
+	// IDs are dropped.
+	MaxStackTraceIDs int
+
+	// EmitDurationSummary controls whether transactions also emit their
+	// duration as a summary, transaction.duration.count and .sum.us,
+	// weighted by the representative count of sampled transactions.
+	EmitDurationSummary bool
+
+	// RejectEmptyMetricsets controls whether metricsets without any
+	// samples, and without a transaction, span or service target to
+	// relate them to, are rejected.
+	RejectEmptyMetricsets bool
+
+	// NormalizeHTTPResult controls whether transactions with an HTTP
+	// response status code emit its class, e.g. "HTTP 2xx", as their
+	// result, rather than the result sent by the agent.
+	NormalizeHTTPResult bool
+
+	// CoerceBooleanLabels controls whether transaction label values of
+	// "true" or "false", including metadata labels, are emitted as booleans.
+	CoerceBooleanLabels bool
+
+	// MaxTypeLength, if positive, is the maximum length in characters of
+	// transaction types, defaulting to 1024. Longer types are rejected,
+	// unless TruncateOverlong is set.
+	MaxTypeLength int
+
+	// TruncateOverlong controls whether transaction types exceeding
+	// MaxTypeLength are truncated and marked with an ellipsis, rather
+	// than rejected.
+	TruncateOverlong bool
+
+	// EmitEventDropped controls whether transactions also emit their
+	// span_count.dropped as event.dropped.
+	EmitEventDropped bool
+
+	// StringifyLabels controls whether boolean and numeric label values,
+	// including metadata labels, are emitted as strings for transactions
+	// and metricsets, rather than with their original type. It takes
+	// precedence over CoerceBooleanLabels.
+	StringifyLabels bool
+
+	// UnknownServiceName, if non-empty, is used as the service name of
+	// transactions recorded without one, neither in the event nor in
+	// the metadata.
+	UnknownServiceName string
+
+	// CollapseNameWhitespace controls whether runs of whitespace in
+	// transaction names are collapsed to a single space.
+	CollapseNameWhitespace bool
+
+	// DefaultSampledByService maps service names to whether their
+	// transactions are sampled when the agent does not say so. Services
+	// not in the map default to sampled.
+	DefaultSampledByService map[string]bool
+
+	// DefaultSpanCount controls whether sampled transactions without
+	// span_count.started are emitted with a span_count.started of 0,
+	// distinguishing them from unsampled transactions. The default is
+	// applied after OmitZeroSpanCount, and so is not omitted.
+	DefaultSpanCount bool
+
+	// MarkZeroDurationAsSpan controls whether transactions with a zero
+	// duration, such as RUM marks, are emitted with processor.event
+	// "span" rather than "transaction".
+	MarkZeroDurationAsSpan bool
+
+	// MaxRequestHeaders, if positive, is the maximum number of request
+	// headers retained for transactions. The headers retained are the
+	// first ones by name in sorted order.
+	MaxRequestHeaders int
+
+	// SamplesAsArray controls whether metricset samples are emitted
+	// as an array of name/value objects under metricset.samples,
+	// rather than as nested objects keyed by sample name.
+	SamplesAsArray bool
+
+	// HoistCommonUnit controls whether a unit shared by all samples of a
+	// metricset is emitted once, as metricset.unit, rather than for each
+	// sample. Samples with differing units keep their own units.
+	HoistCommonUnit bool
+
+	// MaxFutureSkew, if positive, is the maximum duration by which
+	// transaction timestamps may be ahead of the time at which they are
+	// transformed, as reported by transform.Context.IngestTime. Later
+	// transactions are dropped, unless ClampFutureTimestamps is set.
+	MaxFutureSkew time.Duration
+
+	// ClampFutureTimestamps controls whether transaction timestamps
+	// exceeding MaxFutureSkew are replaced by the transformation time,
+	// rather than the transactions being dropped.
+	ClampFutureTimestamps bool
+
+	// EmitEventCreated controls whether transactions emit event.created,
+	// holding the timestamp recorded by the agent, or the time at which
+	// the event is transformed if the agent recorded none.
+	EmitEventCreated bool
+
+	// EmitEventReference controls whether child transactions also emit
+	// their parent id as event.reference.
+	EmitEventReference bool
+
+	// ConvertUnitsToBase controls whether metricset samples in multiples
+	// of bytes, e.g. "kb" or "mb", are converted to bytes, with unit "byte".
+	ConvertUnitsToBase bool
+
+	// MaxRequestBodyBytes, if positive, is the maximum size in bytes of
+	// the captured string request bodies of transactions. Longer bodies
+	// are truncated, without splitting multi-byte characters. Bodies are
+	// not truncated by default, nor for other events.
+	MaxRequestBodyBytes int
+
+	// EmitRefererDomain controls whether transactions emit the domain of
+	// their page referer as http.request.referrer.domain.
+	EmitRefererDomain bool
+
+	// CanonicalizeMessageHeaders controls whether message header names
+	// are canonicalized like HTTP header names, e.g. "content-type" to
+	// "Content-Type". By default they are kept as sent.
+	CanonicalizeMessageHeaders bool
+
+	// CollectAllErrors controls whether decoding a transaction reports
+	// the failures of all its top-level fields, its context and its
+	// timestamp at once, as a utility.Errors, rather than only the first.
+	CollectAllErrors bool
+
+	// AcceptArrayTags controls whether context tags sent as an array of
+	// {"key": ..., "value": ...} objects are accepted as labels, rather
+	// than rejected. Malformed elements are skipped.
+	AcceptArrayTags bool
+}
+
+// DecodeTimestamp decodes the timestamp held in raw[key]: either a number of
+// microseconds since the Unix epoch, or a string holding a signed offset in
+// microseconds from RequestTime, e.g. "+1500" or "-250". The zero time is
+// returned if the timestamp is absent.
+func (input Input) DecodeTimestamp(raw map[string]interface{}, key string) (time.Time, error) {
+	offset, ok := raw[key].(string)
+	if !ok {
+		decoder := utility.ManualDecoder{}
+		timestamp := decoder.TimeEpochMicro(raw, key)
+		return timestamp, decoder.Err
+	}
+	if offset == "" || (offset[0] != '+' && offset[0] != '-') {
+		return time.Time{}, fmt.Errorf("invalid relative timestamp %q: expected a leading sign", offset)
+	}
+	us, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid relative timestamp %q: expected a number of microseconds", offset)
+	}
+	return input.RequestTime.Add(time.Duration(us) * time.Microsecond).UTC(), nil
 }
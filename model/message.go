@@ -28,14 +28,17 @@ import (
 
 // Message holds information about a recorded message, such as the message body and meta information
 type Message struct {
-	Body      *string
-	Headers   http.Header
-	AgeMillis *int
-	QueueName *string
+	Body       *string
+	Headers    http.Header
+	AgeMillis  *int
+	QueueName  *string
+	RoutingKey *string
+	Exchange   *string
 }
 
-// DecodeMessage parses a Message from given input
-func DecodeMessage(input interface{}, err error) (*Message, error) {
+// DecodeMessage parses a Message from given input. Header names are kept
+// as given, unless canonicalizeHeaders is set.
+func DecodeMessage(input interface{}, canonicalizeHeaders bool, err error) (*Message, error) {
 	if input == nil || err != nil {
 		return nil, err
 	}
@@ -49,11 +52,23 @@ func DecodeMessage(input interface{}, err error) (*Message, error) {
 	if decoder.Err != nil || messageInp == nil {
 		return nil, decoder.Err
 	}
+	decodeHeaders := decoder.RawHeaders
+	if canonicalizeHeaders {
+		decodeHeaders = decoder.Headers
+	}
 	m := Message{
-		QueueName: decoder.StringPtr(messageInp, "name", "queue"),
-		Body:      decoder.StringPtr(messageInp, "body"),
-		Headers:   decoder.Headers(messageInp, "headers"),
-		AgeMillis: decoder.IntPtr(messageInp, "ms", "age"),
+		QueueName:  decoder.StringPtr(messageInp, "name", "queue"),
+		Body:       decoder.StringPtr(messageInp, "body"),
+		Headers:    decodeHeaders(messageInp, "headers"),
+		AgeMillis:  decoder.IntPtr(messageInp, "ms", "age"),
+		RoutingKey: decoder.StringPtr(messageInp, "routing_key"),
+		Exchange:   decoder.StringPtr(messageInp, "exchange"),
+	}
+	if m.QueueName == nil {
+		// some agents send the queue name flat, as message.queue
+		if queue, ok := messageInp["queue"].(string); ok {
+			m.QueueName = &queue
+		}
 	}
 	if decoder.Err != nil {
 		return nil, decoder.Err
@@ -75,5 +90,16 @@ func (m *Message) Fields() common.MapStr {
 	}
 	utility.Set(fields, "body", m.Body)
 	utility.Set(fields, "headers", m.Headers)
+	utility.Set(fields, "routing_key", m.RoutingKey)
+	utility.Set(fields, "exchange", m.Exchange)
 	return fields
 }
+
+// EstimatedSize returns a cheap estimate of the serialized size of the
+// message in bytes, without marshaling it.
+func (m *Message) EstimatedSize() int {
+	if m == nil {
+		return 0
+	}
+	return utility.EstimatedSize(m.Body, m.Headers, m.AgeMillis, m.QueueName, m.RoutingKey, m.Exchange)
+}
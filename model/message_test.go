@@ -33,17 +33,45 @@ import (
 
 func TestDecodeMessage(t *testing.T) {
 	for _, tc := range []struct {
-		name    string
-		inp     interface{}
-		inpErr  error
-		message *Message
-		outpErr error
+		name         string
+		inp          interface{}
+		canonicalize bool
+		inpErr       error
+		message      *Message
+		outpErr      error
 	}{
 		{name: "empty"},
 		{name: "error",
 			inpErr: errors.New("error foo")},
 		{name: "invalid",
 			inp: "foo", outpErr: errors.New("invalid type for message")},
+		{name: "routing",
+			inp: map[string]interface{}{
+				"message": map[string]interface{}{
+					"queue":       map[string]interface{}{"name": "order"},
+					"routing_key": "orders.eu.created",
+					"exchange":    "orders"}},
+			message: &Message{
+				QueueName:  tests.StringPtr("order"),
+				RoutingKey: tests.StringPtr("orders.eu.created"),
+				Exchange:   tests.StringPtr("orders"),
+			},
+		},
+		{name: "nested queue",
+			inp: map[string]interface{}{
+				"message": map[string]interface{}{"queue": map[string]interface{}{"name": "order"}}},
+			message: &Message{QueueName: tests.StringPtr("order")},
+		},
+		{name: "flat queue",
+			inp: map[string]interface{}{
+				"message": map[string]interface{}{"queue": "order"}},
+			message: &Message{QueueName: tests.StringPtr("order")},
+		},
+		{name: "queue without name",
+			inp: map[string]interface{}{
+				"message": map[string]interface{}{"queue": map[string]interface{}{}}},
+			message: &Message{},
+		},
 		{name: "valid",
 			inp: map[string]interface{}{
 				"message": map[string]interface{}{
@@ -54,13 +82,30 @@ func TestDecodeMessage(t *testing.T) {
 			message: &Message{
 				QueueName: tests.StringPtr("order"),
 				Body:      tests.StringPtr("user A ordered book B"),
-				Headers:   http.Header{"Internal": []string{"false"}, "Services": []string{"user", "order"}},
+				Headers:   http.Header{"internal": []string{"false"}, "services": []string{"user", "order"}},
 				AgeMillis: tests.IntPtr(1577958057123),
 			},
 		},
+		{name: "raw headers",
+			inp: map[string]interface{}{
+				"message": map[string]interface{}{
+					"headers": map[string]interface{}{"content-type": "application/json", "X-REQUEST-ID": "1ax3"}}},
+			message: &Message{
+				Headers: http.Header{"content-type": []string{"application/json"}, "X-REQUEST-ID": []string{"1ax3"}},
+			},
+		},
+		{name: "canonicalized headers",
+			inp: map[string]interface{}{
+				"message": map[string]interface{}{
+					"headers": map[string]interface{}{"content-type": "application/json", "X-REQUEST-ID": "1ax3"}}},
+			canonicalize: true,
+			message: &Message{
+				Headers: http.Header{"Content-Type": []string{"application/json"}, "X-Request-Id": []string{"1ax3"}},
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			decoded, err := DecodeMessage(tc.inp, tc.inpErr)
+			decoded, err := DecodeMessage(tc.inp, tc.canonicalize, tc.inpErr)
 			if tc.inpErr != nil {
 				require.Equal(t, tc.inpErr, err)
 			} else if tc.outpErr != nil {
@@ -92,4 +137,15 @@ func TestMessaging_Fields(t *testing.T) {
 		"headers": http.Header{"Internal": []string{"false"}, "Services": []string{"user", "order"}},
 		"age":     common.MapStr{"ms": 1577958057123}}
 	assert.Equal(t, outp, m.Fields())
+
+	m = &Message{
+		QueueName:  tests.StringPtr("orders"),
+		RoutingKey: tests.StringPtr("orders.eu.created"),
+		Exchange:   tests.StringPtr("orders"),
+	}
+	outp = common.MapStr{
+		"queue":       common.MapStr{"name": "orders"},
+		"routing_key": "orders.eu.created",
+		"exchange":    "orders"}
+	assert.Equal(t, outp, m.Fields())
 }
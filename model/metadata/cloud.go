@@ -0,0 +1,91 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package metadata
+
+import (
+	"errors"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+
+	"github.com/elastic/apm-server/utility"
+)
+
+// Cloud holds information about the cloud provider and the instance
+// the monitored service is running on, emitted under the ECS cloud.* fields.
+type Cloud struct {
+	Provider         *string
+	Region           *string
+	AvailabilityZone *string
+	InstanceID       *string
+	AccountID        *string
+	MachineType      *string
+}
+
+// DecodeCloud decodes cloud information from input, leaving absent
+// fields unset so that they are omitted from the output.
+func DecodeCloud(input interface{}, err error) (*Cloud, error) {
+	if input == nil || err != nil {
+		return nil, err
+	}
+	raw, ok := input.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid type for cloud")
+	}
+	decoder := utility.ManualDecoder{}
+	return &Cloud{
+		Provider:         decoder.StringPtr(raw, "provider"),
+		Region:           decoder.StringPtr(raw, "region"),
+		AvailabilityZone: decoder.StringPtr(raw, "availability_zone"),
+		InstanceID:       decoder.StringPtr(raw, "id", "instance"),
+		AccountID:        decoder.StringPtr(raw, "id", "account"),
+		MachineType:      decoder.StringPtr(raw, "type", "machine"),
+	}, decoder.Err
+}
+
+// Fields returns a MapStr holding the transformed cloud information.
+func (c *Cloud) Fields() common.MapStr {
+	if c == nil {
+		return nil
+	}
+	cloud := common.MapStr{}
+	utility.Set(cloud, "provider", c.Provider)
+	utility.Set(cloud, "region", c.Region)
+	utility.Set(cloud, "availability_zone", c.AvailabilityZone)
+
+	instance := common.MapStr{}
+	utility.Set(instance, "id", c.InstanceID)
+
+	account := common.MapStr{}
+	utility.Set(account, "id", c.AccountID)
+
+	machine := common.MapStr{}
+	utility.Set(machine, "type", c.MachineType)
+
+	utility.Set(cloud, "instance", instance)
+	utility.Set(cloud, "account", account)
+	utility.Set(cloud, "machine", machine)
+
+	return cloud
+}
+
+func (c *Cloud) estimatedSize() int {
+	if c == nil {
+		return 0
+	}
+	return utility.EstimatedSize(c.Provider, c.Region, c.AvailabilityZone, c.InstanceID, c.AccountID, c.MachineType)
+}
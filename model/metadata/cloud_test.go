@@ -0,0 +1,106 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package metadata
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+func TestCloudTransform(t *testing.T) {
+	provider, region, zone := "aws", "us-east-1", "us-east-1a"
+	instanceID, accountID, machineType := "i-0123", "123456789", "t2.medium"
+
+	tests := []struct {
+		Cloud  Cloud
+		Output common.MapStr
+	}{
+		{
+			Cloud:  Cloud{},
+			Output: common.MapStr{},
+		},
+		{
+			Cloud:  Cloud{Provider: &provider, InstanceID: &instanceID},
+			Output: common.MapStr{"provider": provider, "instance": common.MapStr{"id": instanceID}},
+		},
+		{
+			Cloud: Cloud{
+				Provider:         &provider,
+				Region:           &region,
+				AvailabilityZone: &zone,
+				InstanceID:       &instanceID,
+				AccountID:        &accountID,
+				MachineType:      &machineType,
+			},
+			Output: common.MapStr{
+				"provider":          provider,
+				"region":            region,
+				"availability_zone": zone,
+				"instance":          common.MapStr{"id": instanceID},
+				"account":           common.MapStr{"id": accountID},
+				"machine":           common.MapStr{"type": machineType},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		output := test.Cloud.Fields()
+		assert.Equal(t, test.Output, output)
+	}
+}
+
+func TestCloudDecode(t *testing.T) {
+	provider, region, zone := "gcp", "us-central1", "us-central1-a"
+	instanceID, accountID, machineType := "4306570268266786072", "elastic-apm", "n1-standard-1"
+	for _, test := range []struct {
+		input       interface{}
+		err, inpErr error
+		c           *Cloud
+	}{
+		{input: nil, err: nil, c: nil},
+		{input: nil, inpErr: errors.New("a"), err: errors.New("a"), c: nil},
+		{input: "", err: errors.New("invalid type for cloud"), c: nil},
+		{
+			input: map[string]interface{}{
+				"provider":          provider,
+				"region":            region,
+				"availability_zone": zone,
+				"instance":          map[string]interface{}{"id": instanceID},
+				"account":           map[string]interface{}{"id": accountID},
+				"machine":           map[string]interface{}{"type": machineType},
+			},
+			err: nil,
+			c: &Cloud{
+				Provider:         &provider,
+				Region:           &region,
+				AvailabilityZone: &zone,
+				InstanceID:       &instanceID,
+				AccountID:        &accountID,
+				MachineType:      &machineType,
+			},
+		},
+	} {
+		cloud, out := DecodeCloud(test.input, test.inpErr)
+		assert.Equal(t, test.c, cloud)
+		assert.Equal(t, test.err, out)
+	}
+}
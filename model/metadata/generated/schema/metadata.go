@@ -189,6 +189,11 @@ const ModelSchema = `{
             "type": ["string", "null"],
             "maxLength": 1024
         },
+        "platform_version": {
+            "description": "Version of the system platform the agent is running on.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
         "container": {
             "properties": {
                 "id" : {
@@ -231,6 +236,59 @@ const ModelSchema = `{
                 }
             }
         }
+    }
+        },
+        "cloud": {
+                "$id": "docs/spec/cloud.json",
+    "title": "Cloud",
+    "description": "Cloud provider information of the host the monitored service is running on.",
+    "type": ["object", "null"],
+    "properties": {
+        "provider": {
+            "description": "Name of the cloud provider, e.g. aws, gcp or azure.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "region": {
+            "description": "Region the host is running in.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "availability_zone": {
+            "description": "Availability zone the host is running in.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "instance": {
+            "type": ["object", "null"],
+            "properties": {
+                "id": {
+                    "description": "Instance ID of the host.",
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                }
+            }
+        },
+        "account": {
+            "type": ["object", "null"],
+            "properties": {
+                "id": {
+                    "description": "ID of the cloud account.",
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                }
+            }
+        },
+        "machine": {
+            "type": ["object", "null"],
+            "properties": {
+                "type": {
+                    "description": "Machine type of the host.",
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                }
+            }
+        }
     }
         },
         "user": {
@@ -253,6 +311,11 @@ const ModelSchema = `{
             "description": "The username of the logged in user",
             "type": ["string", "null"],
             "maxLength": 1024
+        },
+        "domain": {
+            "description": "Domain of the logged in user, e.g. a Windows or Active Directory domain",
+            "type": ["string", "null"],
+            "maxLength": 1024
         }
     }
         },
@@ -37,6 +37,10 @@ func TestKubernetesTransform(t *testing.T) {
 			Kubernetes: Kubernetes{},
 			Output:     common.MapStr{},
 		},
+		{
+			Kubernetes: Kubernetes{PodUID: &poduid},
+			Output:     common.MapStr{"pod": common.MapStr{"uid": poduid}},
+		},
 		{
 			Kubernetes: Kubernetes{
 				Namespace: &namespace,
@@ -71,6 +75,10 @@ func TestKubernetesDecode(t *testing.T) {
 		{input: nil, err: nil, k: nil},
 		{input: nil, inpErr: errors.New("a"), err: errors.New("a"), k: nil},
 		{input: "", err: errors.New("invalid type for kubernetes"), k: nil},
+		{
+			input: map[string]interface{}{"pod": map[string]interface{}{"uid": poduid}},
+			k:     &Kubernetes{PodUID: &poduid},
+		},
 		{
 			input: map[string]interface{}{
 				"namespace": namespace,
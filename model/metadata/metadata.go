@@ -48,6 +48,7 @@ type Metadata struct {
 	Process *Process
 	System  *System
 	User    *User
+	Cloud   *Cloud
 	Labels  common.MapStr
 }
 
@@ -67,11 +68,13 @@ func DecodeMetadata(input interface{}, hasShortFieldNames bool) (*Metadata, erro
 	var system *System
 	var process *Process
 	var user *User
+	var cloud *Cloud
 	var labels common.MapStr
 	service, err = DecodeService(raw[fieldName("service")], hasShortFieldNames, err)
 	system, err = DecodeSystem(raw["system"], err)
 	process, err = DecodeProcess(raw["process"], err)
 	user, err = DecodeUser(raw[fieldName("user")], hasShortFieldNames, err)
+	cloud, err = DecodeCloud(raw["cloud"], err)
 	labels, err = DecodeLabels(raw[fieldName("labels")], err)
 
 	if err != nil {
@@ -82,6 +85,7 @@ func DecodeMetadata(input interface{}, hasShortFieldNames bool) (*Metadata, erro
 		System:  system,
 		Process: process,
 		User:    user,
+		Cloud:   cloud,
 		Labels:  labels,
 	}, nil
 }
@@ -98,11 +102,23 @@ func (m *Metadata) Set(fields common.MapStr) common.MapStr {
 	utility.Set(fields, "user_agent", m.User.UserAgentFields())
 	utility.Set(fields, "container", containerFields)
 	utility.Set(fields, "kubernetes", m.System.kubernetesFields())
+	utility.Set(fields, "cloud", m.Cloud.Fields())
 	// to be merged with specific event labels, these should be overwritten in case of conflict
 	utility.Set(fields, "labels", m.Labels)
 	return fields
 }
 
+// EstimatedSize returns a cheap estimate of the serialized size of the
+// metadata in bytes, without marshaling it.
+func (m *Metadata) EstimatedSize() int {
+	return m.Service.EstimatedSize() +
+		m.Process.estimatedSize() +
+		m.System.estimatedSize() +
+		m.User.EstimatedSize() +
+		m.Cloud.estimatedSize() +
+		utility.EstimatedSize(m.Labels)
+}
+
 func get(m common.MapStr, key string) string {
 	if val, ok := m[key].(string); ok {
 		return val
@@ -34,6 +34,7 @@ func TestDecodeMetadata(t *testing.T) {
 	uid := "12321"
 	mail := "user@email.com"
 	agentName := "elastic-node"
+	provider, region := "aws", "us-east-1"
 
 	for _, test := range []struct {
 		input  interface{}
@@ -68,6 +69,10 @@ func TestDecodeMetadata(t *testing.T) {
 			input: map[string]interface{}{"user": 123},
 			err:   errors.New("invalid type for user"),
 		},
+		{
+			input: map[string]interface{}{"cloud": 123},
+			err:   errors.New("invalid type for cloud"),
+		},
 		{
 			input: map[string]interface{}{
 				"process": map[string]interface{}{
@@ -87,6 +92,9 @@ func TestDecodeMetadata(t *testing.T) {
 				"user": map[string]interface{}{
 					"id": uid, "email": mail,
 				},
+				"cloud": map[string]interface{}{
+					"provider": provider, "region": region,
+				},
 				"labels": map[string]interface{}{
 					"k": "v", "n": 1, "f": 1.5, "b": false,
 				},
@@ -100,6 +108,7 @@ func TestDecodeMetadata(t *testing.T) {
 				System:  &System{DetectedHostname: &host},
 				Process: &Process{Pid: pid},
 				User:    &User{Id: &uid, Email: &mail},
+				Cloud:   &Cloud{Provider: &provider, Region: &region},
 				Labels:  common.MapStr{"k": "v", "n": 1, "f": 1.5, "b": false},
 			},
 		},
@@ -124,10 +133,12 @@ func TestMetadata_Set(t *testing.T) {
 	pid := 1234
 	host := "host"
 	containerID := "container-123"
+	podUID := "pod-uid-123"
 	serviceName, serviceNodeName := "myservice", "serviceABC"
 	uid := "12321"
 	mail := "user@email.com"
 	agentName := "elastic-node"
+	provider, region := "aws", "us-east-1"
 
 	for _, test := range []struct {
 		input  Metadata
@@ -147,6 +158,7 @@ func TestMetadata_Set(t *testing.T) {
 				System:  &System{DetectedHostname: &host, Container: &Container{ID: containerID}},
 				Process: &Process{Pid: pid},
 				User:    &User{Id: &uid, Email: &mail},
+				Cloud:   &Cloud{Provider: &provider, Region: &region},
 			},
 			fields: common.MapStr{
 				"foo": "bar",
@@ -158,6 +170,7 @@ func TestMetadata_Set(t *testing.T) {
 				"foo":       "bar",
 				"agent":     common.MapStr{"version": "1.0.0", "name": "elastic-node"},
 				"container": common.MapStr{"id": containerID},
+				"cloud":     common.MapStr{"provider": provider, "region": region},
 				"host":      common.MapStr{"hostname": host, "name": host},
 				"process":   common.MapStr{"pid": pid},
 				"service": common.MapStr{
@@ -178,6 +191,15 @@ func TestMetadata_Set(t *testing.T) {
 				"container": common.MapStr{"id": containerID},
 				"service":   common.MapStr{"node": common.MapStr{"name": containerID}}},
 		},
+		{
+			input: Metadata{
+				Service: &Service{},
+				System:  &System{DetectedHostname: &host, Kubernetes: &Kubernetes{PodUID: &podUID}},
+			},
+			fields: common.MapStr{},
+			output: common.MapStr{
+				"kubernetes": common.MapStr{"pod": common.MapStr{"uid": podUID}}},
+		},
 		{
 			input: Metadata{
 				Service: &Service{},
@@ -192,3 +214,18 @@ func TestMetadata_Set(t *testing.T) {
 		assert.Equal(t, test.output, test.input.Set(test.fields))
 	}
 }
+
+func TestMetadataContainerRoundTrip(t *testing.T) {
+	containerID := "container-123"
+	md, err := DecodeMetadata(map[string]interface{}{
+		"system": map[string]interface{}{
+			"container": map[string]interface{}{"id": containerID},
+		},
+	}, false)
+	require.NoError(t, err)
+	require.NotNil(t, md)
+	assert.Equal(t, &System{Container: &Container{ID: containerID}}, md.System)
+	assert.Equal(t, common.MapStr{
+		"container": common.MapStr{"id": containerID},
+	}, md.Set(common.MapStr{}))
+}
@@ -64,3 +64,10 @@ func (p *Process) fields() common.MapStr {
 
 	return svc
 }
+
+func (p *Process) estimatedSize() int {
+	if p == nil {
+		return 0
+	}
+	return utility.EstimatedSize(p.Pid, p.Ppid, p.Title, p.Argv)
+}
@@ -167,3 +167,19 @@ func (a *Agent) fields() common.MapStr {
 	utility.Set(agent, "ephemeral_id", a.EphemeralId)
 	return agent
 }
+
+// EstimatedSize returns a cheap estimate of the serialized size of the
+// service in bytes, without marshaling it.
+func (s *Service) EstimatedSize() int {
+	if s == nil {
+		return 0
+	}
+	return utility.EstimatedSize(
+		s.Name, s.Version, s.Environment,
+		s.Language.Name, s.Language.Version,
+		s.Runtime.Name, s.Runtime.Version,
+		s.Framework.Name, s.Framework.Version,
+		s.Agent.Name, s.Agent.Version, s.Agent.EphemeralId,
+		s.Node.Name,
+	)
+}
@@ -60,6 +60,18 @@ func TestServiceTransform(t *testing.T) {
 			HostName:    "bar",
 			AgentFields: common.MapStr{},
 			Fields:      common.MapStr{"node": common.MapStr{"name": "bar"}},
+		}, {
+			Service: Service{
+				Language:  Language{Name: &langName},
+				Runtime:   Runtime{Version: &rtVersion},
+				Framework: Framework{Name: &fwName},
+			},
+			AgentFields: common.MapStr{},
+			Fields: common.MapStr{
+				"language":  common.MapStr{"name": "ecmascript"},
+				"runtime":   common.MapStr{"version": "8.0.0"},
+				"framework": common.MapStr{"name": "Express"},
+			},
 		},
 		{
 			Service: Service{
@@ -137,6 +149,20 @@ func TestServiceDecode(t *testing.T) {
 				Agent:     Agent{},
 			},
 		},
+		{
+			input: map[string]interface{}{
+				"name":      serviceName,
+				"language":  common.MapStr{"name": "ecmascript"},
+				"runtime":   common.MapStr{"version": "8.0.0"},
+				"framework": common.MapStr{"name": "Express"},
+			},
+			s: &Service{
+				Name:      &serviceName,
+				Language:  Language{Name: &langName},
+				Runtime:   Runtime{Version: &rtVersion},
+				Framework: Framework{Name: &fwName},
+			},
+		},
 		{
 			input: map[string]interface{}{
 				"name":        serviceName,
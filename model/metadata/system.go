@@ -27,10 +27,12 @@ import (
 )
 
 type System struct {
+	ID                 *string
 	DetectedHostname   *string
 	ConfiguredHostname *string
 	Architecture       *string
 	Platform           *string
+	PlatformVersion    *string
 	IP                 net.IP
 
 	Container  *Container
@@ -47,9 +49,11 @@ func DecodeSystem(input interface{}, err error) (*System, error) {
 	}
 	decoder := utility.ManualDecoder{}
 	system := System{
-		Platform:     decoder.StringPtr(raw, "platform"),
-		Architecture: decoder.StringPtr(raw, "architecture"),
-		IP:           decoder.NetIP(raw, "ip"),
+		ID:              decoder.StringPtr(raw, "id"),
+		Platform:        decoder.StringPtr(raw, "platform"),
+		PlatformVersion: decoder.StringPtr(raw, "platform_version"),
+		Architecture:    decoder.StringPtr(raw, "architecture"),
+		IP:              decoder.NetIP(raw, "ip"),
 	}
 	if system.Container, err = DecodeContainer(raw["container"], err); err != nil {
 		return nil, err
@@ -105,11 +109,17 @@ func (s *System) fields() common.MapStr {
 		return nil
 	}
 	system := common.MapStr{}
+	utility.Set(system, "id", s.ID)
 	utility.Set(system, "hostname", s.hostname())
 	utility.Set(system, "name", s.name())
 	utility.Set(system, "architecture", s.Architecture)
 	if s.Platform != nil {
-		utility.Set(system, "os", common.MapStr{"platform": s.Platform})
+		os := common.MapStr{"platform": s.Platform}
+		if s.PlatformVersion != nil {
+			// ECS host.os.full, e.g. "darwin 19.4.0"
+			utility.Set(os, "full", *s.Platform+" "+*s.PlatformVersion)
+		}
+		utility.Set(system, "os", os)
 	}
 	if s.IP != nil {
 		utility.Set(system, "ip", s.IP.String())
@@ -130,3 +140,20 @@ func (s *System) kubernetesFields() common.MapStr {
 	}
 	return s.Kubernetes.fields()
 }
+
+func (s *System) estimatedSize() int {
+	if s == nil {
+		return 0
+	}
+	size := utility.EstimatedSize(
+		s.ID, s.DetectedHostname, s.ConfiguredHostname,
+		s.Architecture, s.Platform, s.PlatformVersion, s.IP,
+	)
+	if s.Container != nil {
+		size += len(s.Container.ID)
+	}
+	if k := s.Kubernetes; k != nil {
+		size += utility.EstimatedSize(k.Namespace, k.NodeName, k.PodName, k.PodUID)
+	}
+	return size
+}
@@ -36,6 +36,8 @@ import (
 func TestSystem(t *testing.T) {
 	host, configured, detected := "host", "custom hostname", "detected hostname"
 	arch, platform, ip, containerID, namespace := "amd", "osx", "127.0.0.1", "1234", "staging"
+	platformVersion := "10.15.4"
+	hostID := "fd0a9cd5-2b6a-4b4a-8f5b-0e9c2fd9c6a1"
 	nodename, podname, podUID := "a.node", "a.pod", "b.podID"
 
 	inpErr := errors.New("some error")
@@ -151,6 +153,18 @@ func TestSystem(t *testing.T) {
 			},
 			s: &System{Kubernetes: &Kubernetes{}, DetectedHostname: &detected, ConfiguredHostname: &configured},
 		},
+		"host id": {
+			input: map[string]interface{}{"id": hostID, "detected_hostname": detected},
+			s:     &System{ID: &hostID, DetectedHostname: &detected},
+		},
+		"platform version": {
+			input: map[string]interface{}{"platform": platform, "platform_version": platformVersion},
+			s:     &System{Platform: &platform, PlatformVersion: &platformVersion},
+		},
+		"platform version without platform": {
+			input: map[string]interface{}{"platform_version": platformVersion},
+			s:     &System{PlatformVersion: &platformVersion},
+		},
 		"full hostname info": {
 			input: map[string]interface{}{
 				"detected_hostname":   detected,
@@ -33,6 +33,7 @@ type User struct {
 	Id        *string
 	Email     *string
 	Name      *string
+	Domain    *string
 	IP        net.IP
 	UserAgent *string
 }
@@ -51,6 +52,7 @@ func DecodeUser(input interface{}, hasShortFieldNames bool, err error) (*User, e
 		UserAgent: decoder.StringPtr(raw, "user-agent"),
 		Name:      decoder.StringPtr(raw, fieldName("username")),
 		Email:     decoder.StringPtr(raw, fieldName("email")),
+		Domain:    decoder.StringPtr(raw, "domain"),
 		IP:        decoder.NetIP(raw, "ip"),
 	}
 
@@ -75,6 +77,7 @@ func (u *User) Fields() common.MapStr {
 	utility.Set(user, "id", u.Id)
 	utility.Set(user, "email", u.Email)
 	utility.Set(user, "name", u.Name)
+	utility.Set(user, "domain", u.Domain)
 	return user
 }
 
@@ -91,3 +94,12 @@ func (u *User) UserAgentFields() common.MapStr {
 	}
 	return common.MapStr{"original": *u.UserAgent}
 }
+
+// EstimatedSize returns a cheap estimate of the serialized size of the
+// user in bytes, without marshaling it.
+func (u *User) EstimatedSize() int {
+	if u == nil {
+		return 0
+	}
+	return utility.EstimatedSize(u.Id, u.Email, u.Name, u.Domain, u.IP, u.UserAgent)
+}
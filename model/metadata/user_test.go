@@ -33,6 +33,7 @@ func TestUserFields(t *testing.T) {
 	ip := net.ParseIP("127.0.0.1")
 	email := "test@mail.co"
 	name := "user123"
+	domain := "CORP"
 	userAgent := "rum-1.0"
 
 	tests := []struct {
@@ -49,14 +50,20 @@ func TestUserFields(t *testing.T) {
 				IP:        ip,
 				Email:     &email,
 				Name:      &name,
+				Domain:    &domain,
 				UserAgent: &userAgent,
 			},
 			Output: common.MapStr{
-				"id":    "1234",
-				"email": "test@mail.co",
-				"name":  "user123",
+				"id":     "1234",
+				"email":  "test@mail.co",
+				"name":   "user123",
+				"domain": "CORP",
 			},
 		},
+		{
+			User:   User{Email: &email, Domain: &domain},
+			Output: common.MapStr{"email": "test@mail.co", "domain": "CORP"},
+		},
 	}
 
 	for _, test := range tests {
@@ -127,7 +134,7 @@ func TestUserAgentFields(t *testing.T) {
 }
 
 func TestUserDecode(t *testing.T) {
-	id, mail, name, ip, agent := "12", "m@g.dk", "foo", "127.0.0.1", "ruby"
+	id, mail, name, domain, ip, agent := "12", "m@g.dk", "foo", "CORP", "127.0.0.1", "ruby"
 	inpErr := errors.New("some error happened")
 	for _, test := range []struct {
 		input    interface{}
@@ -141,11 +148,11 @@ func TestUserDecode(t *testing.T) {
 		{input: map[string]interface{}{"id": json.Number("12")}, inputErr: nil, err: nil, u: &User{Id: &id}},
 		{
 			input: map[string]interface{}{
-				"id": id, "email": mail, "username": name, "ip": ip, "user-agent": agent,
+				"id": id, "email": mail, "username": name, "domain": domain, "ip": ip, "user-agent": agent,
 			},
 			err: nil,
 			u: &User{
-				Id: &id, Email: &mail, Name: &name, IP: net.ParseIP(ip), UserAgent: &agent,
+				Id: &id, Email: &mail, Name: &name, Domain: &domain, IP: net.ParseIP(ip), UserAgent: &agent,
 			},
 		},
 	} {
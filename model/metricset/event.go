@@ -19,8 +19,13 @@ package metricset
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/santhosh-tekuri/jsonschema"
@@ -30,6 +35,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/logp"
 	"github.com/elastic/beats/v7/libbeat/monitoring"
 
+	"github.com/elastic/apm-server/decoder"
 	logs "github.com/elastic/apm-server/log"
 	"github.com/elastic/apm-server/model"
 	"github.com/elastic/apm-server/model/metadata"
@@ -44,11 +50,54 @@ const (
 	docType        = "metric"
 	transactionKey = "transaction"
 	spanKey        = "span"
+	serviceKey     = "service"
 )
 
+// knownUnits holds the sample units accepted outside of experimental mode.
+var knownUnits = map[string]bool{
+	"byte":    true,
+	"percent": true,
+	"ns":      true,
+	"us":      true,
+	"ms":      true,
+	"s":       true,
+}
+
+// byteUnits maps sample units to their size in bytes, for converting
+// them to the base unit "byte".
+var byteUnits = map[string]int64{
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+}
+
+const (
+	gaugeType   = "gauge"
+	counterType = "counter"
+	summaryType = "summary"
+)
+
+// sampleTypes holds the sample types accepted when decoding.
+var sampleTypes = map[string]bool{
+	gaugeType:   true,
+	counterType: true,
+	summaryType: true,
+}
+
+// statsdTypes maps StatsD metric types to sample types. Timings have no
+// sample type of their own, they are recorded as gauges in milliseconds.
+var statsdTypes = map[string]string{
+	"c":              counterType,
+	"g":              gaugeType,
+	statsdTimingType: gaugeType,
+}
+
+const statsdTimingType = "ms"
+
 var (
 	Metrics         = monitoring.Default.NewRegistry("apm-server.processor.metric")
 	transformations = monitoring.NewInt(Metrics, "transformations")
+	droppedSamples  = monitoring.NewInt(Metrics, "samples.dropped")
 	processorEntry  = common.MapStr{"name": processorName, "event": docType}
 )
 
@@ -61,6 +110,26 @@ func ModelSchema() *jsonschema.Schema {
 type Sample struct {
 	Name  string
 	Value float64
+
+	// IntValue holds the exact value of samples sent as integers that
+	// fit in an int64, which may not be representable by Value.
+	IntValue *int64
+
+	// Type is the kind of metric, e.g. "counter", if known.
+	Type *string
+
+	// Unit is the unit of the sample's value(s), e.g. "ms", if known.
+	Unit *string
+
+	// Values and Counts hold a pre-aggregated histogram, and are
+	// mutually exclusive with Value.
+	Values []float64
+	Counts []int64
+
+	// Sum and Count hold a pre-aggregated summary, for samples
+	// of type "summary".
+	Sum   float64
+	Count int64
 }
 
 // Transaction provides enough information to connect a metricset to the related kind of transactions
@@ -71,23 +140,111 @@ type Transaction struct {
 
 // Span provides enough information to connect a metricset to the related kind of spans
 type Span struct {
-	Type    *string
-	Subtype *string
+	Type               *string
+	Subtype            *string
+	DestinationService *DestinationService
+}
+
+// DestinationService identifies the destination service of the spans a metricset relates to
+type DestinationService struct {
+	Resource *string
+}
+
+// ServiceTarget identifies the target service of the spans a metricset relates to
+type ServiceTarget struct {
+	Type *string
+	Name *string
+}
+
+// ServiceOrigin identifies the service originating the spans a metricset relates to
+type ServiceOrigin struct {
+	Id      *string
+	Name    *string
+	Version *string
 }
 
 type Metricset struct {
-	Metadata    metadata.Metadata
-	Samples     []*Sample
-	Labels      common.MapStr
-	Transaction *Transaction
-	Span        *Span
-	Timestamp   time.Time
+	Metadata      metadata.Metadata
+	Samples       []*Sample
+	Labels        common.MapStr
+	Transaction   *Transaction
+	Span          *Span
+	ServiceTarget *ServiceTarget
+	ServiceOrigin *ServiceOrigin
+	Timestamp     time.Time
+
+	// ServiceVersion, if set, overrides the service version from metadata.
+	ServiceVersion *string
+
+	// DroppedSamples holds the number of samples dropped while decoding
+	// due to invalid values.
+	DroppedSamples int
+
+	// DimensionLabels names the labels, including metadata labels,
+	// to be emitted under dimensions rather than labels.
+	DimensionLabels []string
+
+	// StringifyLabels controls whether boolean and numeric label values
+	// are emitted as strings.
+	StringifyLabels bool
+
+	// HoistCommonUnit controls whether a unit shared by all samples is
+	// emitted once, as metricset.unit, rather than for each sample.
+	HoistCommonUnit bool
+
+	// SamplesAsArray controls whether samples are emitted as an array
+	// under metricset.samples.
+	SamplesAsArray bool
+
+	// NormalizeDestinationResource controls whether the span destination
+	// resource is emitted lowercased and stripped of any path. The decoded
+	// resource is left as sent by the agent.
+	NormalizeDestinationResource bool
 }
 
 type metricsetDecoder struct {
 	*utility.ManualDecoder
 }
 
+// DecodeEvents decodes either a single metricset, or a batch of metricsets
+// held in a top-level "metricsets" array, each decoded with DecodeEvent.
+//
+// A batch is decoded in full even if some of its metricsets are invalid:
+// the valid metricsets are returned in order, along with an error for the
+// first invalid one, identifying it by its index in the batch. Callers may
+// therefore receive both metricsets and a non-nil error, and should keep
+// the metricsets. A single metricset is returned on its own, or with its
+// decoding error and no metricsets.
+func DecodeEvents(input model.Input) ([]transform.Transformable, error) {
+	raw, ok := input.Raw.(map[string]interface{})
+	if !ok || raw["metricsets"] == nil {
+		tr, err := DecodeEvent(input)
+		if err != nil {
+			return nil, err
+		}
+		return []transform.Transformable{tr}, nil
+	}
+	batch, ok := raw["metricsets"].([]interface{})
+	if !ok {
+		return nil, errors.New("invalid type for metricsets in metric event")
+	}
+	var firstErr error
+	out := make([]transform.Transformable, 0, len(batch))
+	for i, item := range batch {
+		itemInput := input
+		itemInput.Raw = item
+		tr, err := DecodeEvent(itemInput)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("invalid metricset at index %d: %w", i, err)
+			}
+			continue
+		}
+		out = append(out, tr)
+	}
+	return out, firstErr
+}
+
 func DecodeEvent(input model.Input) (transform.Transformable, error) {
 	if input.Raw == nil {
 		return nil, errors.New("no data for metric event")
@@ -98,18 +255,105 @@ func DecodeEvent(input model.Input) (transform.Transformable, error) {
 	}
 
 	md := metricsetDecoder{&utility.ManualDecoder{}}
+	samples := md.decodeSamples(raw["samples"])
+	return md.decodeMetricset(raw, samples, input)
+}
+
+// DecodeEventFromReader decodes a single JSON encoded metricset event from r,
+// using cfg and adding meta to the event. The request time is taken to be
+// the current time.
+//
+// The samples are read one at a time, so that they are never held in an
+// intermediate map; the remaining fields are decoded as in DecodeEvent.
+func DecodeEventFromReader(r io.Reader, cfg model.Config, meta metadata.Metadata) (transform.Transformable, error) {
+	d := decoder.NewJSONDecoder(r)
+	md := metricsetDecoder{&utility.ManualDecoder{}}
+	raw := make(map[string]interface{})
+	var samples []*Sample
+	var hasSamples bool
+	value, ok, err := decoder.DecodeObject(d, func(key string) error {
+		if key != "samples" {
+			var value interface{}
+			err := d.Decode(&value)
+			raw[key] = value
+			return err
+		}
+		var err error
+		hasSamples = true
+		md.Err = nil
+		samples, err = md.readSamples(d)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	input := model.Input{Raw: value, RequestTime: time.Now(), Metadata: meta, Config: cfg}
+	if !ok {
+		return DecodeEvent(input)
+	}
+	input.Raw = raw
+	if !hasSamples {
+		samples = md.decodeSamples(nil)
+	}
+	return md.decodeMetricset(raw, samples, input)
+}
+
+// decodeMetricset decodes all but the samples of a metricset from raw.
+func (md *metricsetDecoder) decodeMetricset(raw map[string]interface{}, samples []*Sample, input model.Input) (transform.Transformable, error) {
 	e := Metricset{
-		Samples:     md.decodeSamples(raw["samples"]),
-		Transaction: md.decodeTransaction(raw[transactionKey]),
-		Span:        md.decodeSpan(raw[spanKey]),
-		Timestamp:   md.TimeEpochMicro(raw, "timestamp"),
-		Metadata:    input.Metadata,
+		Samples:         samples,
+		Transaction:     md.decodeTransaction(raw[transactionKey]),
+		Span:            md.decodeSpan(raw[spanKey]),
+		ServiceTarget:   md.decodeServiceTarget(raw[serviceKey]),
+		ServiceOrigin:   md.decodeServiceOrigin(raw[serviceKey]),
+		ServiceVersion:  md.StringPtr(raw, "version", serviceKey),
+		Metadata:        input.Metadata,
+		DimensionLabels: input.Config.DimensionLabels,
+		StringifyLabels: input.Config.StringifyLabels,
+		HoistCommonUnit: input.Config.HoistCommonUnit,
+		SamplesAsArray:  input.Config.SamplesAsArray,
+
+		NormalizeDestinationResource: input.Config.NormalizeDestinationResource,
 	}
 
 	if md.Err != nil {
 		return nil, md.Err
 	}
-
+	timestamp, err := input.DecodeTimestamp(raw, "timestamp")
+	if err != nil {
+		return nil, err
+	}
+	e.Timestamp = timestamp
+
+	samples = e.Samples[:0]
+	for _, sample := range e.Samples {
+		if sample != nil && !sample.isFinite() {
+			if !input.Config.DropInvalidMetrics {
+				return nil, fmt.Errorf("invalid sample: %s: value must be a finite number", sample.Name)
+			}
+			e.DroppedSamples++
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	e.Samples = samples
+	if input.Config.ConvertUnitsToBase {
+		for _, sample := range e.Samples {
+			if sample != nil {
+				sample.convertToBaseUnit()
+			}
+		}
+	}
+	if !input.Config.Experimental {
+		for _, sample := range e.Samples {
+			if sample != nil && sample.Unit != nil && !knownUnits[*sample.Unit] {
+				return nil, fmt.Errorf("invalid sample: %s: unknown unit %q", sample.Name, *sample.Unit)
+			}
+		}
+	}
+	if input.Config.RejectEmptyMetricsets && e.isEmpty() {
+		return nil, errors.New("empty metricset: no samples and no transaction, span or service target")
+	}
 	if tags := utility.Prune(md.MapStr(raw, "tags")); len(tags) > 0 {
 		e.Labels = tags
 	}
@@ -120,6 +364,38 @@ func DecodeEvent(input model.Input) (transform.Transformable, error) {
 	return &e, nil
 }
 
+// DecodeStatsD decodes a StatsD line of the form "name:value|type" into a
+// metricset with a single sample. Metadata and timestamp are taken from base.
+func DecodeStatsD(line string, base model.Input) (*Metricset, error) {
+	idx := strings.IndexByte(line, ':')
+	if idx <= 0 {
+		return nil, fmt.Errorf("invalid statsd line: %q", line)
+	}
+	name := line[:idx]
+	parts := strings.Split(line[idx+1:], "|")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid statsd line: %q", line)
+	}
+	sampleType, ok := statsdTypes[parts[1]]
+	if !ok {
+		return nil, fmt.Errorf("invalid statsd metric type: %q", parts[1])
+	}
+	value, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid statsd value: %q", parts[0])
+	}
+	sample := Sample{Name: name, Value: value, Type: &sampleType}
+	if parts[1] == statsdTimingType {
+		unit := "ms"
+		sample.Unit = &unit
+	}
+	return &Metricset{
+		Metadata:  base.Metadata,
+		Samples:   []*Sample{&sample},
+		Timestamp: base.RequestTime,
+	}, nil
+}
+
 func (md *metricsetDecoder) decodeSamples(input interface{}) []*Sample {
 	if input == nil {
 		md.Err = errors.New("no samples for metric event")
@@ -131,28 +407,190 @@ func (md *metricsetDecoder) decodeSamples(input interface{}) []*Sample {
 		return nil
 	}
 
+	// allocate all samples at once, rather than one at a time
+	backing := make([]Sample, len(raw))
 	samples := make([]*Sample, len(raw))
 	i := 0
 	for name, s := range raw {
 		if s == nil {
 			continue
 		}
-		sampleMap, ok := s.(map[string]interface{})
-		if !ok {
-			md.Err = fmt.Errorf("invalid sample: %s: %s", name, s)
+		sample := &backing[i]
+		if !md.decodeSample(sample, name, s) {
 			return nil
 		}
+		samples[i] = sample
+		i++
+	}
+	// null samples are skipped, leaving no nil entries
+	return samples[:i]
+}
 
-		samples[i] = &Sample{
-			Name:  name,
-			Value: md.Float64(sampleMap, "value"),
+// readSamples reads the samples object of a metricset from d, decoding
+// one sample at a time. As when decoding into a map, a repeated sample
+// name replaces the earlier sample.
+func (md *metricsetDecoder) readSamples(d *json.Decoder) ([]*Sample, error) {
+	samples := []*Sample{}
+	index := make(map[string]int)
+	value, ok, err := decoder.DecodeObject(d, func(name string) error {
+		var s interface{}
+		if err := d.Decode(&s); err != nil {
+			return err
 		}
-		if md.Err != nil {
+		var sample *Sample
+		if s != nil && md.Err == nil {
+			sample = &Sample{}
+			if !md.decodeSample(sample, name, s) {
+				sample = nil
+			}
+		}
+		if i, ok := index[name]; ok {
+			samples[i] = sample
+			return nil
+		}
+		index[name] = len(samples)
+		samples = append(samples, sample)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return md.decodeSamples(value), nil
+	}
+	if md.Err != nil {
+		return nil, nil
+	}
+	// null samples are skipped, leaving no nil entries
+	i := 0
+	for _, sample := range samples {
+		if sample != nil {
+			samples[i] = sample
+			i++
+		}
+	}
+	return samples[:i], nil
+}
+
+// decodeSample decodes the sample name from s into sample,
+// reporting whether it is valid.
+func (md *metricsetDecoder) decodeSample(sample *Sample, name string, s interface{}) bool {
+	sampleMap, ok := s.(map[string]interface{})
+	if !ok {
+		md.Err = fmt.Errorf("invalid sample: %s: %s", name, s)
+		return false
+	}
+
+	sample.Name = name
+	sample.Unit = md.StringPtr(sampleMap, "unit")
+	sample.Type = md.StringPtr(sampleMap, "type")
+	if sample.Type != nil && !sampleTypes[*sample.Type] {
+		md.Err = fmt.Errorf("invalid sample: %s: unknown type %q", name, *sample.Type)
+		return false
+	}
+	_, hasValue := sampleMap["value"]
+	_, hasValues := sampleMap["values"]
+	_, hasCounts := sampleMap["counts"]
+	if sample.isSummary() {
+		sample.Sum = md.Float64(sampleMap, "sum")
+		if count := md.Int64Ptr(sampleMap, "count"); count != nil {
+			sample.Count = *count
+		}
+	} else if hasValues || hasCounts {
+		if hasValue {
+			md.Err = fmt.Errorf("invalid sample: %s: value cannot be combined with values and counts", name)
+			return false
+		}
+		sample.Values = md.float64Slice(sampleMap, "values")
+		sample.Counts = md.int64Slice(sampleMap, "counts")
+		if md.Err == nil && len(sample.Values) != len(sample.Counts) {
+			md.Err = fmt.Errorf("invalid sample: %s: values and counts must have equal length", name)
+		}
+	} else if value, ok := sampleValue(sampleMap); ok {
+		sample.Value = value
+		if intValue, err := sampleMap["value"].(json.Number).Int64(); err == nil {
+			sample.IntValue = &intValue
+		}
+	} else if str, ok := sampleMap["value"].(string); ok {
+		// Some agents send string-encoded numbers.
+		value, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			md.Err = fmt.Errorf("invalid sample: %s: value %q is not a number", name, str)
+			return false
+		}
+		sample.Value = value
+		if intValue, err := strconv.ParseInt(str, 10, 64); err == nil {
+			sample.IntValue = &intValue
+		}
+	} else {
+		sample.Value = md.Float64(sampleMap, "value")
+	}
+	return md.Err == nil
+}
+
+// sampleValue is a fast path for decoding the value of a sample in its
+// common shape, a json.Number. Any other shape is left to the decoder.
+func sampleValue(sampleMap map[string]interface{}) (float64, bool) {
+	number, ok := sampleMap["value"].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	value, err := number.Float64()
+	return value, err == nil
+}
+
+func (md *metricsetDecoder) float64Slice(raw map[string]interface{}, key string) []float64 {
+	arr := md.InterfaceArr(raw, key)
+	if md.Err != nil || arr == nil {
+		return nil
+	}
+	values := make([]float64, len(arr))
+	for i, v := range arr {
+		switch v := v.(type) {
+		case json.Number:
+			f, err := v.Float64()
+			if err != nil {
+				md.Err = err
+				return nil
+			}
+			values[i] = f
+		case float64:
+			values[i] = v
+		default:
+			md.Err = utility.ErrFetch
+			return nil
+		}
+	}
+	return values
+}
+
+func (md *metricsetDecoder) int64Slice(raw map[string]interface{}, key string) []int64 {
+	arr := md.InterfaceArr(raw, key)
+	if md.Err != nil || arr == nil {
+		return nil
+	}
+	values := make([]int64, len(arr))
+	for i, v := range arr {
+		switch v := v.(type) {
+		case json.Number:
+			n, err := v.Int64()
+			if err != nil {
+				md.Err = err
+				return nil
+			}
+			values[i] = n
+		case float64:
+			if v != float64(int64(v)) {
+				md.Err = utility.ErrFetch
+				return nil
+			}
+			values[i] = int64(v)
+		default:
+			md.Err = utility.ErrFetch
 			return nil
 		}
-		i++
 	}
-	return samples
+	return values
 }
 
 func (md *metricsetDecoder) decodeSpan(input interface{}) *Span {
@@ -165,11 +603,25 @@ func (md *metricsetDecoder) decodeSpan(input interface{}) *Span {
 		return nil
 	}
 
-	return &Span{
+	span := Span{
 		Type:    md.StringPtr(raw, "type"),
 		Subtype: md.StringPtr(raw, "subtype"),
 	}
+	if resource := md.StringPtr(raw, "resource", "destination", "service"); resource != nil {
+		span.DestinationService = &DestinationService{Resource: resource}
+	}
+	return &span
+}
+
+// normalizeDestinationResource lowercases resource and strips any path,
+// so that e.g. "mysql/users" and "mysql" are grouped.
+func normalizeDestinationResource(resource string) string {
+	if idx := strings.IndexByte(resource, '/'); idx >= 0 {
+		resource = resource[:idx]
+	}
+	return strings.ToLower(resource)
 }
+
 func (md *metricsetDecoder) decodeTransaction(input interface{}) *Transaction {
 	if input == nil {
 		return nil
@@ -186,13 +638,162 @@ func (md *metricsetDecoder) decodeTransaction(input interface{}) *Transaction {
 	}
 }
 
-func (s *Span) fields() common.MapStr {
+func (md *metricsetDecoder) decodeServiceTarget(input interface{}) *ServiceTarget {
+	if input == nil {
+		return nil
+	}
+	raw, ok := input.(map[string]interface{})
+	if !ok {
+		md.Err = errors.New("invalid type for service in metric event")
+		return nil
+	}
+	target := ServiceTarget{
+		Type: md.StringPtr(raw, "type", "target"),
+		Name: md.StringPtr(raw, "name", "target"),
+	}
+	if target.Type == nil && target.Name == nil {
+		return nil
+	}
+	return &target
+}
+
+func (md *metricsetDecoder) decodeServiceOrigin(input interface{}) *ServiceOrigin {
+	raw, ok := input.(map[string]interface{})
+	if !ok {
+		// invalid types are reported by decodeServiceTarget
+		return nil
+	}
+	origin := ServiceOrigin{
+		Id:      md.StringPtr(raw, "id", "origin"),
+		Name:    md.StringPtr(raw, "name", "origin"),
+		Version: md.StringPtr(raw, "version", "origin"),
+	}
+	if origin.Id == nil && origin.Name == nil && origin.Version == nil {
+		return nil
+	}
+	return &origin
+}
+
+// commonUnit returns the unit shared by all samples, or nil if there are
+// no samples or any sample has a different or no unit.
+func (me *Metricset) commonUnit() *string {
+	var unit *string
+	for _, sample := range me.Samples {
+		if sample == nil {
+			continue
+		}
+		if sample.Unit == nil || (unit != nil && *unit != *sample.Unit) {
+			return nil
+		}
+		unit = sample.Unit
+	}
+	return unit
+}
+
+// value returns the sample's value, preferring the exact IntValue if set.
+func (s *Sample) value() interface{} {
+	if s.IntValue != nil {
+		return *s.IntValue
+	}
+	return s.Value
+}
+
+// convertToBaseUnit converts the sample's value(s) from a multiple of
+// bytes, e.g. "kb", to bytes. Samples in other units are left as is.
+func (s *Sample) convertToBaseUnit() {
+	if s.Unit == nil {
+		return
+	}
+	factor, ok := byteUnits[*s.Unit]
+	if !ok {
+		return
+	}
+	unit := "byte"
+	s.Unit = &unit
+	s.Value *= float64(factor)
+	s.Sum *= float64(factor)
+	for i := range s.Values {
+		s.Values[i] *= float64(factor)
+	}
+	if s.IntValue != nil {
+		if value := *s.IntValue * factor; value/factor == *s.IntValue {
+			s.IntValue = &value
+		} else {
+			// the converted value overflows, fall back to Value
+			s.IntValue = nil
+		}
+	}
+}
+
+// isFinite reports whether the sample's value(s) are neither NaN nor infinite.
+func (s *Sample) isFinite() bool {
+	if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+		return false
+	}
+	for _, v := range s.Values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// isEmpty reports whether the metricset holds no samples, and relates
+// to no transaction, span or service target.
+func (me *Metricset) isEmpty() bool {
+	for _, sample := range me.Samples {
+		if sample != nil {
+			return false
+		}
+	}
+	return me.Transaction == nil && me.Span == nil && me.ServiceTarget == nil
+}
+
+func (s *Sample) isSummary() bool {
+	return s.Type != nil && *s.Type == summaryType
+}
+
+func (s *Sample) isHistogram() bool {
+	return s.Values != nil || s.Counts != nil
+}
+
+func (s *Span) fields(normalizeResource bool) common.MapStr {
 	if s == nil {
 		return nil
 	}
 	fields := common.MapStr{}
 	utility.Set(fields, "type", s.Type)
 	utility.Set(fields, "subtype", s.Subtype)
+	if s.DestinationService != nil {
+		var resource interface{} = s.DestinationService.Resource
+		if normalizeResource && s.DestinationService.Resource != nil {
+			resource = normalizeDestinationResource(*s.DestinationService.Resource)
+		}
+		utility.Set(fields, "destination", common.MapStr{
+			"service": common.MapStr{"resource": resource},
+		})
+	}
+	return fields
+}
+
+func (t *ServiceTarget) fields() common.MapStr {
+	if t == nil {
+		return nil
+	}
+	fields := common.MapStr{}
+	utility.Set(fields, "type", t.Type)
+	utility.Set(fields, "name", t.Name)
+	return fields
+}
+
+func (o *ServiceOrigin) fields() common.MapStr {
+	if o == nil {
+		return nil
+	}
+	fields := common.MapStr{}
+	utility.Set(fields, "id", o.Id)
+	utility.Set(fields, "name", o.Name)
+	utility.Set(fields, "version", o.Version)
 	return fields
 }
 
@@ -211,13 +812,64 @@ func (me *Metricset) Transform(ctx context.Context, tctx *transform.Context) []b
 	if me == nil {
 		return nil
 	}
+	droppedSamples.Add(int64(me.DroppedSamples))
+
+	var commonUnit *string
+	if me.HoistCommonUnit {
+		commonUnit = me.commonUnit()
+	}
+	sampleUnit := func(sample *Sample) *string {
+		if commonUnit != nil {
+			return nil
+		}
+		return sample.Unit
+	}
 
 	fields := common.MapStr{}
-	for _, sample := range me.Samples {
-		if _, err := fields.Put(sample.Name, sample.Value); err != nil {
-			logp.NewLogger(logs.Transform).Warnf("failed to transform sample %#v", sample)
-			continue
+	if me.SamplesAsArray {
+		samples := make([]common.MapStr, 0, len(me.Samples))
+		for _, sample := range me.Samples {
+			sampleFields := common.MapStr{"name": sample.Name}
+			utility.Set(sampleFields, "type", sample.Type)
+			if sample.isSummary() {
+				sampleFields["sum"] = sample.Sum
+				sampleFields["count"] = sample.Count
+			} else if sample.isHistogram() {
+				sampleFields["values"] = sample.Values
+				sampleFields["counts"] = sample.Counts
+			} else {
+				sampleFields["value"] = sample.value()
+			}
+			utility.Set(sampleFields, "unit", sampleUnit(sample))
+			samples = append(samples, sampleFields)
+		}
+		utility.Set(fields, "metricset", common.MapStr{"samples": samples})
+	} else {
+		descriptions := common.MapStr{}
+		for _, sample := range me.Samples {
+			value := sample.value()
+			if sample.isSummary() {
+				value = common.MapStr{"sum": sample.Sum, "count": sample.Count}
+			} else if sample.isHistogram() {
+				value = common.MapStr{"values": sample.Values, "counts": sample.Counts}
+			}
+			if _, err := fields.Put(sample.Name, value); err != nil {
+				logp.NewLogger(logs.Transform).Warnf("failed to transform sample %#v", sample)
+				continue
+			}
+			// the type and unit are recorded apart from the value, so that
+			// samples of the same name map alike whether or not they have them
+			description := common.MapStr{}
+			utility.Set(description, "type", sample.Type)
+			utility.Set(description, "unit", sampleUnit(sample))
+			if len(description) > 0 {
+				descriptions[sample.Name] = description
+			}
 		}
+		utility.Set(fields, "_metric_descriptions", descriptions)
+	}
+	if commonUnit != nil {
+		utility.DeepUpdate(fields, "metricset.unit", *commonUnit)
 	}
 
 	fields["processor"] = processorEntry
@@ -225,8 +877,24 @@ func (me *Metricset) Transform(ctx context.Context, tctx *transform.Context) []b
 
 	// merges with metadata labels, overrides conflicting keys
 	utility.DeepUpdate(fields, "labels", me.Labels)
+	if labels, ok := fields["labels"].(common.MapStr); ok && me.StringifyLabels {
+		fields["labels"] = model.StringLabels(labels)
+	}
+	if len(me.DimensionLabels) > 0 {
+		if labels, ok := fields["labels"].(common.MapStr); ok {
+			labels, dimensions := splitDimensions(labels, me.DimensionLabels)
+			utility.Set(fields, "labels", labels)
+			utility.Set(fields, "dimensions", dimensions)
+		}
+	}
 	utility.DeepUpdate(fields, transactionKey, me.Transaction.fields())
-	utility.DeepUpdate(fields, spanKey, me.Span.fields())
+	utility.DeepUpdate(fields, spanKey, me.Span.fields(me.NormalizeDestinationResource))
+	utility.DeepUpdate(fields, "service.target", me.ServiceTarget.fields())
+	utility.DeepUpdate(fields, "service.origin", me.ServiceOrigin.fields())
+	if me.ServiceVersion != nil {
+		utility.DeepUpdate(fields, "service.version", *me.ServiceVersion)
+	}
+	utility.DeepUpdate(fields, "event.ingested", tctx.IngestTime().UTC().Format(time.RFC3339Nano))
 
 	return []beat.Event{
 		{
@@ -235,3 +903,66 @@ func (me *Metricset) Transform(ctx context.Context, tctx *transform.Context) []b
 		},
 	}
 }
+
+// splitDimensions returns the labels named in dimensions separately from
+// the remaining labels. The given labels are left unmodified, as they may
+// be shared with the metadata.
+func splitDimensions(labels common.MapStr, dimensions []string) (common.MapStr, common.MapStr) {
+	isDimension := make(map[string]bool, len(dimensions))
+	for _, name := range dimensions {
+		isDimension[name] = true
+	}
+	rest, dims := common.MapStr{}, common.MapStr{}
+	for k, v := range labels {
+		if isDimension[k] {
+			dims[k] = v
+		} else {
+			rest[k] = v
+		}
+	}
+	return rest, dims
+}
+
+// EstimatedSize returns a cheap estimate of the serialized size of the
+// metricset in bytes, including its labels and metadata, without
+// marshaling it. See utility.EstimatedSize.
+func (me *Metricset) EstimatedSize() int {
+	size := utility.NumericFieldSize // timestamp
+	for _, sample := range me.Samples {
+		if sample == nil {
+			continue
+		}
+		size += utility.EstimatedSize(sample.Name, sample.Type, sample.Unit)
+		switch {
+		case sample.isSummary():
+			size += 2 * utility.NumericFieldSize
+		case sample.isHistogram():
+			size += (len(sample.Values) + len(sample.Counts)) * utility.NumericFieldSize
+		default:
+			size += utility.NumericFieldSize
+		}
+	}
+	for _, s := range me.stringFields() {
+		size += utility.EstimatedSize(s)
+	}
+	return size + utility.EstimatedSize(me.Labels) + me.Metadata.EstimatedSize()
+}
+
+// stringFields returns the optional string fields of the metricset
+// identifying the related transactions, spans and service.
+func (me *Metricset) stringFields() []*string {
+	fields := []*string{me.ServiceVersion}
+	if me.Transaction != nil {
+		fields = append(fields, me.Transaction.Name, me.Transaction.Type)
+	}
+	if me.Span != nil {
+		fields = append(fields, me.Span.Type, me.Span.Subtype)
+	}
+	if me.ServiceTarget != nil {
+		fields = append(fields, me.ServiceTarget.Type, me.ServiceTarget.Name)
+	}
+	if me.ServiceOrigin != nil {
+		fields = append(fields, me.ServiceOrigin.Id, me.ServiceOrigin.Name, me.ServiceOrigin.Version)
+	}
+	return fields
+}
@@ -18,16 +18,23 @@
 package metricset
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/elastic/apm-server/decoder"
 	"github.com/elastic/apm-server/model"
 	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/apm-server/tests/loader"
 	"github.com/elastic/apm-server/utility"
 
 	"github.com/elastic/beats/v7/libbeat/common"
@@ -94,6 +101,62 @@ func TestDecode(t *testing.T) {
 			},
 			err: utility.ErrFetch,
 		},
+		{
+			input: map[string]interface{}{
+				"samples": map[string]interface{}{
+					"latency": map[string]interface{}{
+						"value":  json.Number("1"),
+						"values": []interface{}{json.Number("1.5")},
+						"counts": []interface{}{json.Number("2")},
+					},
+				},
+			},
+			err: errors.New("invalid sample: latency: value cannot be combined with values and counts"),
+		},
+		{
+			input: map[string]interface{}{
+				"samples": map[string]interface{}{
+					"latency": map[string]interface{}{
+						"values": []interface{}{json.Number("1.5"), json.Number("2.5")},
+						"counts": []interface{}{json.Number("2")},
+					},
+				},
+			},
+			err: errors.New("invalid sample: latency: values and counts must have equal length"),
+		},
+		{
+			input: map[string]interface{}{
+				"samples": map[string]interface{}{
+					"latency": map[string]interface{}{
+						"values": []interface{}{json.Number("1.5")},
+						"counts": []interface{}{json.Number("2.5")},
+					},
+				},
+			},
+			err: errors.New("strconv.ParseInt: parsing \"2.5\": invalid syntax"),
+		},
+		{
+			input: map[string]interface{}{
+				"timestamp": tsFormat(timestampParsed),
+				"samples": map[string]interface{}{
+					"latency": map[string]interface{}{
+						"values": []interface{}{json.Number("1.5"), json.Number("2.5"), 10.0},
+						"counts": []interface{}{json.Number("2"), json.Number("7"), 1.0},
+					},
+					"a.counter": map[string]interface{}{
+						"value": json.Number("612"),
+					},
+				},
+			},
+			metricset: &Metricset{
+				Metadata: metadata,
+				Samples: []*Sample{
+					{Name: "latency", Values: []float64{1.5, 2.5, 10}, Counts: []int64{2, 7, 1}},
+					{Name: "a.counter", Value: 612, IntValue: tests.Int64Ptr(612)},
+				},
+				Timestamp: timestampParsed,
+			},
+		},
 		{
 			input: map[string]interface{}{
 				"samples": map[string]interface{}{},
@@ -128,8 +191,9 @@ func TestDecode(t *testing.T) {
 						Value: 9.16,
 					},
 					{
-						Name:  "a.counter",
-						Value: 612,
+						Name:     "a.counter",
+						Value:    612,
+						IntValue: tests.Int64Ptr(612),
 					},
 				},
 				Labels: common.MapStr{
@@ -163,8 +227,9 @@ func TestDecode(t *testing.T) {
 				Metadata: metadata,
 				Samples: []*Sample{
 					{
-						Name:  "a.counter",
-						Value: 612,
+						Name:     "a.counter",
+						Value:    612,
+						IntValue: tests.Int64Ptr(612),
 					},
 				},
 				Labels: common.MapStr{
@@ -175,6 +240,104 @@ func TestDecode(t *testing.T) {
 				Timestamp:   timestampParsed,
 			},
 		},
+		{
+			input: map[string]interface{}{
+				"timestamp": tsFormat(timestampParsed),
+				"samples":   map[string]interface{}{},
+				"span":      map[string]interface{}{"type": spType},
+				"service": map[string]interface{}{
+					"target": map[string]interface{}{"type": "postgresql", "name": "users"},
+				},
+			},
+			metricset: &Metricset{
+				Metadata:      metadata,
+				Samples:       []*Sample{},
+				Span:          &Span{Type: &spType},
+				ServiceTarget: &ServiceTarget{Type: tests.StringPtr("postgresql"), Name: tests.StringPtr("users")},
+				Timestamp:     timestampParsed,
+			},
+		},
+		{
+			input: map[string]interface{}{
+				"timestamp": tsFormat(timestampParsed),
+				"samples":   map[string]interface{}{},
+				"service": map[string]interface{}{
+					"target": map[string]interface{}{"name": "users"},
+				},
+			},
+			metricset: &Metricset{
+				Metadata:      metadata,
+				Samples:       []*Sample{},
+				ServiceTarget: &ServiceTarget{Name: tests.StringPtr("users")},
+				Timestamp:     timestampParsed,
+			},
+		},
+		{
+			input: map[string]interface{}{
+				"timestamp": tsFormat(timestampParsed),
+				"samples":   map[string]interface{}{},
+				"service": map[string]interface{}{
+					"origin": map[string]interface{}{"id": "abc123", "name": "frontend", "version": "1.2.0"},
+					"target": map[string]interface{}{"type": "http", "name": "backend"},
+				},
+			},
+			metricset: &Metricset{
+				Metadata:      metadata,
+				Samples:       []*Sample{},
+				ServiceTarget: &ServiceTarget{Type: tests.StringPtr("http"), Name: tests.StringPtr("backend")},
+				ServiceOrigin: &ServiceOrigin{Id: tests.StringPtr("abc123"), Name: tests.StringPtr("frontend"), Version: tests.StringPtr("1.2.0")},
+				Timestamp:     timestampParsed,
+			},
+		},
+		{
+			input: map[string]interface{}{
+				"timestamp": tsFormat(timestampParsed),
+				"samples":   map[string]interface{}{},
+				"service": map[string]interface{}{
+					"origin": map[string]interface{}{"name": "frontend"},
+				},
+			},
+			metricset: &Metricset{
+				Metadata:      metadata,
+				Samples:       []*Sample{},
+				ServiceOrigin: &ServiceOrigin{Name: tests.StringPtr("frontend")},
+				Timestamp:     timestampParsed,
+			},
+		},
+		{
+			input: map[string]interface{}{
+				"timestamp": tsFormat(timestampParsed),
+				"samples":   map[string]interface{}{},
+				"service": map[string]interface{}{
+					"origin": map[string]interface{}{},
+				},
+			},
+			metricset: &Metricset{
+				Metadata:  metadata,
+				Samples:   []*Sample{},
+				Timestamp: timestampParsed,
+			},
+		},
+		{
+			input: map[string]interface{}{
+				"timestamp": tsFormat(timestampParsed),
+				"samples":   map[string]interface{}{},
+				"service":   map[string]interface{}{"version": "1.1.0"},
+			},
+			metricset: &Metricset{
+				Metadata:       metadata,
+				Samples:        []*Sample{},
+				ServiceVersion: tests.StringPtr("1.1.0"),
+				Timestamp:      timestampParsed,
+			},
+		},
+		{
+			input: map[string]interface{}{
+				"samples": map[string]interface{}{},
+				"service": "postgresql",
+			},
+			err: errors.New("invalid type for service in metric event"),
+		},
 	} {
 		transformables, err := DecodeEvent(model.Input{
 			Raw:         test.input,
@@ -183,6 +346,9 @@ func TestDecode(t *testing.T) {
 		})
 		if test.err != nil {
 			assert.Error(t, err)
+			if test.err != utility.ErrFetch {
+				assert.EqualError(t, err, test.err.Error())
+			}
 		}
 
 		if test.metricset != nil {
@@ -193,8 +359,590 @@ func TestDecode(t *testing.T) {
 	}
 }
 
+func TestDecodeNormalizeDestinationResource(t *testing.T) {
+	for name, test := range map[string]struct {
+		resource  string
+		normalize bool
+		expected  string
+	}{
+		"plain":                 {resource: "mysql", normalize: true, expected: "mysql"},
+		"pathful":               {resource: "mysql/users", normalize: true, expected: "mysql"},
+		"mixed case with path":  {resource: "MySQL/Users", normalize: true, expected: "mysql"},
+		"pathful, no normalize": {resource: "MySQL/users", normalize: false, expected: "MySQL/users"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"samples": map[string]interface{}{},
+					"span": map[string]interface{}{
+						"type":        "db",
+						"destination": map[string]interface{}{"service": map[string]interface{}{"resource": test.resource}},
+					},
+				},
+				Config: model.Config{NormalizeDestinationResource: test.normalize},
+			})
+			require.NoError(t, err)
+			ms := transformable.(*Metricset)
+			require.NotNil(t, ms.Span.DestinationService)
+			assert.Equal(t, test.resource, *ms.Span.DestinationService.Resource, "decoded resource is kept as sent")
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			resource, err := output[0].Fields.GetValue("span.destination.service.resource")
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, resource)
+		})
+	}
+}
+
+func TestDecodeSampleUnit(t *testing.T) {
+	for name, test := range map[string]struct {
+		unit         string
+		experimental bool
+		err          string
+	}{
+		"known":                 {unit: "ms"},
+		"unknown":               {unit: "furlongs", err: `invalid sample: distance: unknown unit "furlongs"`},
+		"unknown, experimental": {unit: "furlongs", experimental: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"samples": map[string]interface{}{
+						"distance": map[string]interface{}{"value": json.Number("1.5"), "unit": test.unit},
+					},
+				},
+				Config: model.Config{Experimental: test.experimental},
+			})
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			samples := transformable.(*Metricset).Samples
+			require.Len(t, samples, 1)
+			assert.Equal(t, &test.unit, samples[0].Unit)
+		})
+	}
+}
+
+func TestDecodeSampleType(t *testing.T) {
+	for name, test := range map[string]struct {
+		sample   map[string]interface{}
+		expected Sample
+		err      string
+	}{
+		"omitted": {
+			sample:   map[string]interface{}{"value": json.Number("1")},
+			expected: Sample{Name: "a", Value: 1, IntValue: tests.Int64Ptr(1)},
+		},
+		"gauge": {
+			sample:   map[string]interface{}{"value": json.Number("1"), "type": "gauge"},
+			expected: Sample{Name: "a", Value: 1, IntValue: tests.Int64Ptr(1), Type: tests.StringPtr("gauge")},
+		},
+		"counter": {
+			sample:   map[string]interface{}{"value": json.Number("42"), "type": "counter"},
+			expected: Sample{Name: "a", Value: 42, IntValue: tests.Int64Ptr(42), Type: tests.StringPtr("counter")},
+		},
+		"summary": {
+			sample:   map[string]interface{}{"type": "summary", "sum": json.Number("12.5"), "count": json.Number("5")},
+			expected: Sample{Name: "a", Type: tests.StringPtr("summary"), Sum: 12.5, Count: 5},
+		},
+		"unknown": {
+			sample: map[string]interface{}{"value": json.Number("1"), "type": "histogram"},
+			err:    `invalid sample: a: unknown type "histogram"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{"samples": map[string]interface{}{"a": test.sample}},
+			})
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []*Sample{&test.expected}, transformable.(*Metricset).Samples)
+		})
+	}
+}
+
+func TestTransformSampleType(t *testing.T) {
+	metricset := &Metricset{Samples: []*Sample{
+		{Name: "a.gauge", Value: 1},
+		{Name: "b.counter", Value: 42, Type: tests.StringPtr("counter")},
+		{Name: "c.summary", Type: tests.StringPtr("summary"), Sum: 12.5, Count: 5},
+		{Name: "d.timer", Value: 3, Type: tests.StringPtr("gauge"), Unit: tests.StringPtr("ms")},
+	}}
+	output := metricset.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	fields := output[0].Fields
+	// the type does not change the shape of the value
+	assert.Equal(t, common.MapStr{"gauge": 1.0}, fields["a"])
+	assert.Equal(t, common.MapStr{"counter": 42.0}, fields["b"])
+	assert.Equal(t, common.MapStr{"summary": common.MapStr{"sum": 12.5, "count": int64(5)}}, fields["c"])
+	assert.Equal(t, common.MapStr{"timer": 3.0}, fields["d"])
+	assert.Equal(t, common.MapStr{
+		"b.counter": common.MapStr{"type": "counter"},
+		"c.summary": common.MapStr{"type": "summary"},
+		"d.timer":   common.MapStr{"type": "gauge", "unit": "ms"},
+	}, fields["_metric_descriptions"])
+}
+
+func TestDecodeStringSampleValue(t *testing.T) {
+	for name, test := range map[string]struct {
+		value    interface{}
+		expected float64
+		err      string
+	}{
+		"numeric":        {value: json.Number("9.16"), expected: 9.16},
+		"string-numeric": {value: "9.16", expected: 9.16},
+		"garbage":        {value: "abc", err: `invalid sample: a.gauge: value "abc" is not a number`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"samples": map[string]interface{}{
+						"a.gauge": map[string]interface{}{"value": test.value},
+					},
+				},
+			})
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []*Sample{{Name: "a.gauge", Value: test.expected}}, transformable.(*Metricset).Samples)
+		})
+	}
+}
+
+func TestDecodeIntegerSampleValue(t *testing.T) {
+	const large = int64(9007199254740993) // 2^53 + 1, not representable as float64
+	for name, test := range map[string]struct {
+		value    interface{}
+		intValue *int64
+		output   interface{}
+	}{
+		"small":             {value: json.Number("42"), intValue: tests.Int64Ptr(42), output: int64(42)},
+		"large":             {value: json.Number("9007199254740993"), intValue: tests.Int64Ptr(large), output: large},
+		"fractional":        {value: json.Number("1.5"), output: 1.5},
+		"overflow":          {value: json.Number("18446744073709551616"), output: float64(18446744073709551616)},
+		"string small":      {value: "42", intValue: tests.Int64Ptr(42), output: int64(42)},
+		"string large":      {value: "9007199254740993", intValue: tests.Int64Ptr(large), output: large},
+		"string fractional": {value: "1.5", output: 1.5},
+		"string overflow":   {value: "18446744073709551616", output: float64(18446744073709551616)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"samples": map[string]interface{}{
+						"a.counter": map[string]interface{}{"value": test.value},
+					},
+				},
+			})
+			require.NoError(t, err)
+			metricset := transformable.(*Metricset)
+			require.Len(t, metricset.Samples, 1)
+			assert.Equal(t, test.intValue, metricset.Samples[0].IntValue)
+
+			output := metricset.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			assert.Equal(t, common.MapStr{"counter": test.output}, output[0].Fields["a"])
+		})
+	}
+}
+
+func TestDecodeNonFiniteSamples(t *testing.T) {
+	for name, test := range map[string]struct {
+		value   interface{}
+		drop    bool
+		err     string
+		samples []*Sample
+		dropped int
+	}{
+		"normal":        {value: json.Number("1.5"), samples: []*Sample{{Name: "a.gauge", Value: 1.5}, {Name: "b.counter", Value: 2, IntValue: tests.Int64Ptr(2)}}},
+		"NaN":           {value: json.Number("NaN"), err: "invalid sample: a.gauge: value must be a finite number"},
+		"+Inf":          {value: math.Inf(1), err: "invalid sample: a.gauge: value must be a finite number"},
+		"-Inf":          {value: json.Number("-Inf"), err: "invalid sample: a.gauge: value must be a finite number"},
+		"NaN, dropped":  {value: json.Number("NaN"), drop: true, samples: []*Sample{{Name: "b.counter", Value: 2, IntValue: tests.Int64Ptr(2)}}, dropped: 1},
+		"+Inf, dropped": {value: math.Inf(1), drop: true, samples: []*Sample{{Name: "b.counter", Value: 2, IntValue: tests.Int64Ptr(2)}}, dropped: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"samples": map[string]interface{}{
+						"a.gauge":   map[string]interface{}{"value": test.value},
+						"b.counter": map[string]interface{}{"value": json.Number("2")},
+					},
+				},
+				Config: model.Config{DropInvalidMetrics: test.drop},
+			})
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			metricset := transformable.(*Metricset)
+			assert.ElementsMatch(t, test.samples, metricset.Samples)
+			assert.Equal(t, test.dropped, metricset.DroppedSamples)
+		})
+	}
+}
+
+func TestTransformDroppedSamples(t *testing.T) {
+	before := droppedSamples.Get()
+	metricset := &Metricset{Samples: []*Sample{{Name: "a.counter", Value: 1}}, DroppedSamples: 2}
+	metricset.Transform(context.Background(), &transform.Context{})
+	assert.Equal(t, before+2, droppedSamples.Get())
+}
+
+func TestTransformEventIngested(t *testing.T) {
+	now := time.Date(2019, 1, 3, 15, 17, 5, 123456789, time.FixedZone("CET", 3600))
+	metricset := &Metricset{Samples: []*Sample{{Name: "a.counter", Value: 1}}}
+
+	tctx := &transform.Context{Now: func() time.Time { return now }}
+	output := metricset.Transform(context.Background(), tctx)
+	require.Len(t, output, 1)
+	assert.Equal(t, common.MapStr{"ingested": "2019-01-03T14:17:05.123456789Z"}, output[0].Fields["event"])
+}
+
+func TestTransformDimensionLabels(t *testing.T) {
+	raw := map[string]interface{}{
+		"samples": map[string]interface{}{"a.counter": map[string]interface{}{"value": json.Number("1")}},
+		"tags":    map[string]interface{}{"host_group": "blue", "pod": "p-1", "note": "ad hoc"},
+	}
+	metadataLabels := common.MapStr{"region": "eu", "team": "apm"}
+	for name, test := range map[string]struct {
+		dimensions []string
+		labels     interface{}
+		expected   interface{}
+	}{
+		"no dimensions": {
+			labels: common.MapStr{"host_group": "blue", "pod": "p-1", "note": "ad hoc", "region": "eu", "team": "apm"},
+		},
+		"event and metadata dimensions": {
+			dimensions: []string{"pod", "region", "unknown"},
+			labels:     common.MapStr{"host_group": "blue", "note": "ad hoc", "team": "apm"},
+			expected:   common.MapStr{"pod": "p-1", "region": "eu"},
+		},
+		"only dimensions": {
+			dimensions: []string{"host_group", "pod", "note", "region", "team"},
+			expected:   common.MapStr{"host_group": "blue", "pod": "p-1", "note": "ad hoc", "region": "eu", "team": "apm"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw:      raw,
+				Metadata: metadata.Metadata{Labels: metadataLabels},
+				Config:   model.Config{DimensionLabels: test.dimensions},
+			})
+			require.NoError(t, err)
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			assert.Equal(t, test.labels, output[0].Fields["labels"])
+			assert.Equal(t, test.expected, output[0].Fields["dimensions"])
+			assert.Equal(t, common.MapStr{"region": "eu", "team": "apm"}, metadataLabels)
+		})
+	}
+}
+
+func TestTransformStringifyLabels(t *testing.T) {
+	raw := map[string]interface{}{
+		"samples": map[string]interface{}{"a.counter": map[string]interface{}{"value": json.Number("1")}},
+		"tags":    map[string]interface{}{"name": "checkout", "count": json.Number("2"), "ratio": 0.5, "cached": true},
+	}
+	metadataLabels := common.MapStr{"canary": false, "zone": "a"}
+	for name, test := range map[string]struct {
+		stringify bool
+		expected  common.MapStr
+	}{
+		"preserved": {
+			expected: common.MapStr{"name": "checkout", "count": int64(2), "ratio": common.Float(0.5), "cached": true, "canary": false, "zone": "a"},
+		},
+		"stringified": {
+			stringify: true,
+			expected:  common.MapStr{"name": "checkout", "count": "2", "ratio": "0.5", "cached": "true", "canary": "false", "zone": "a"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw:      raw,
+				Metadata: metadata.Metadata{Labels: metadataLabels},
+				Config:   model.Config{StringifyLabels: test.stringify},
+			})
+			require.NoError(t, err)
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			assert.Equal(t, test.expected, output[0].Fields["labels"])
+			assert.Equal(t, common.MapStr{"canary": false, "zone": "a"}, metadataLabels)
+		})
+	}
+}
+
+func TestDecodeRelativeTimestamp(t *testing.T) {
+	requestTime := time.Date(2019, 1, 3, 15, 17, 4, 0, time.UTC)
+	for name, test := range map[string]struct {
+		timestamp interface{}
+		expected  time.Time
+		err       string
+	}{
+		"absolute":        {timestamp: json.Number("1496170422281000"), expected: time.Date(2017, 5, 30, 18, 53, 42, 281000000, time.UTC)},
+		"absent":          {expected: requestTime},
+		"positive offset": {timestamp: "+1500", expected: requestTime.Add(1500 * time.Microsecond)},
+		"negative offset": {timestamp: "-2000000", expected: requestTime.Add(-2 * time.Second)},
+		"missing sign":    {timestamp: "1500", err: "invalid relative timestamp \"1500\": expected a leading sign"},
+		"malformed sign":  {timestamp: "+-1500", err: "invalid relative timestamp \"+-1500\": expected a number of microseconds"},
+		"sign only":       {timestamp: "-", err: "invalid relative timestamp \"-\""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]interface{}{"samples": map[string]interface{}{"a.counter": map[string]interface{}{"value": json.Number("1")}}}
+			if test.timestamp != nil {
+				raw["timestamp"] = test.timestamp
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: raw, RequestTime: requestTime})
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, transformable.(*Metricset).Timestamp)
+		})
+	}
+}
+
+func TestDecodeNullSample(t *testing.T) {
+	transformable, err := DecodeEvent(model.Input{Raw: map[string]interface{}{
+		"samples": map[string]interface{}{
+			"a.counter": map[string]interface{}{"value": json.Number("1")},
+			"b.gauge":   nil,
+		},
+	}})
+	require.NoError(t, err)
+	metricset := transformable.(*Metricset)
+	assert.Equal(t, []*Sample{{Name: "a.counter", Value: 1, IntValue: tests.Int64Ptr(1)}}, metricset.Samples)
+
+	// neither panics on the skipped sample
+	assert.NotZero(t, metricset.EstimatedSize())
+	output := metricset.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	assert.Equal(t, common.MapStr{"counter": int64(1)}, output[0].Fields["a"])
+	assert.NotContains(t, output[0].Fields, "b")
+}
+
+func TestDecodeRejectEmptyMetricsets(t *testing.T) {
+	for name, test := range map[string]struct {
+		raw   map[string]interface{}
+		empty bool
+	}{
+		"no samples": {
+			raw:   map[string]interface{}{"samples": map[string]interface{}{}},
+			empty: true,
+		},
+		"null samples only": {
+			raw:   map[string]interface{}{"samples": map[string]interface{}{"a.counter": nil}},
+			empty: true,
+		},
+		"all samples dropped": {
+			raw:   map[string]interface{}{"samples": map[string]interface{}{"a.counter": map[string]interface{}{"value": math.NaN()}}},
+			empty: true,
+		},
+		"samples": {
+			raw: map[string]interface{}{"samples": map[string]interface{}{"a.counter": map[string]interface{}{"value": json.Number("1")}}},
+		},
+		"transaction only": {
+			raw: map[string]interface{}{"samples": map[string]interface{}{}, "transaction": map[string]interface{}{"name": "GET /"}},
+		},
+		"span only": {
+			raw: map[string]interface{}{"samples": map[string]interface{}{}, "span": map[string]interface{}{"type": "db"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{Raw: test.raw, Config: model.Config{DropInvalidMetrics: true}})
+			require.NoError(t, err)
+			require.NotNil(t, transformable)
+
+			transformable, err = DecodeEvent(model.Input{Raw: test.raw, Config: model.Config{DropInvalidMetrics: true, RejectEmptyMetricsets: true}})
+			if test.empty {
+				assert.EqualError(t, err, "empty metricset: no samples and no transaction, span or service target")
+				assert.Nil(t, transformable)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, transformable)
+			}
+		})
+	}
+}
+
+func TestDecodeEvents(t *testing.T) {
+	valid := func(name string) map[string]interface{} {
+		return map[string]interface{}{
+			"samples":     map[string]interface{}{"a.counter": map[string]interface{}{"value": json.Number("1")}},
+			"transaction": map[string]interface{}{"name": name, "type": "request"},
+		}
+	}
+	invalid := map[string]interface{}{"samples": "invalid"}
+
+	t.Run("single", func(t *testing.T) {
+		transformables, err := DecodeEvents(model.Input{Raw: valid("GET /")})
+		require.NoError(t, err)
+		require.Len(t, transformables, 1)
+		assert.Equal(t, tests.StringPtr("GET /"), transformables[0].(*Metricset).Transaction.Name)
+	})
+	t.Run("single invalid", func(t *testing.T) {
+		transformables, err := DecodeEvents(model.Input{Raw: invalid})
+		assert.EqualError(t, err, "invalid type for samples in metric event")
+		assert.Nil(t, transformables)
+	})
+	t.Run("batch", func(t *testing.T) {
+		transformables, err := DecodeEvents(model.Input{Raw: map[string]interface{}{
+			"metricsets": []interface{}{valid("GET /"), valid("POST /")},
+		}})
+		require.NoError(t, err)
+		require.Len(t, transformables, 2)
+		assert.Equal(t, tests.StringPtr("GET /"), transformables[0].(*Metricset).Transaction.Name)
+		assert.Equal(t, tests.StringPtr("POST /"), transformables[1].(*Metricset).Transaction.Name)
+	})
+	t.Run("batch of two, one invalid", func(t *testing.T) {
+		transformables, err := DecodeEvents(model.Input{Raw: map[string]interface{}{
+			"metricsets": []interface{}{valid("GET /"), invalid},
+		}})
+		assert.EqualError(t, err, "invalid metricset at index 1: invalid type for samples in metric event")
+		require.Len(t, transformables, 1)
+		assert.Equal(t, tests.StringPtr("GET /"), transformables[0].(*Metricset).Transaction.Name)
+	})
+	t.Run("batch partially invalid", func(t *testing.T) {
+		transformables, err := DecodeEvents(model.Input{Raw: map[string]interface{}{
+			"metricsets": []interface{}{invalid, valid("GET /"), "invalid"},
+		}})
+		assert.EqualError(t, err, "invalid metricset at index 0: invalid type for samples in metric event")
+		require.Len(t, transformables, 1)
+		assert.Equal(t, tests.StringPtr("GET /"), transformables[0].(*Metricset).Transaction.Name)
+	})
+	t.Run("batch invalid type", func(t *testing.T) {
+		transformables, err := DecodeEvents(model.Input{Raw: map[string]interface{}{"metricsets": "invalid"}})
+		assert.EqualError(t, err, "invalid type for metricsets in metric event")
+		assert.Nil(t, transformables)
+	})
+}
+
+func TestDecodeStatsD(t *testing.T) {
+	requestTime := time.Date(2019, 1, 3, 15, 17, 4, 908.596*1e6, time.FixedZone("+0100", 3600))
+	base := model.Input{
+		RequestTime: requestTime,
+		Metadata:    metadata.Metadata{Service: &metadata.Service{Name: tests.StringPtr("myservice")}},
+	}
+
+	for name, test := range map[string]struct {
+		line   string
+		sample Sample
+	}{
+		"counter": {line: "requests:3|c", sample: Sample{Name: "requests", Value: 3, Type: tests.StringPtr("counter")}},
+		"gauge":   {line: "queue.size:-2.5|g", sample: Sample{Name: "queue.size", Value: -2.5, Type: tests.StringPtr("gauge")}},
+		"timing":  {line: "request.duration:320|ms", sample: Sample{Name: "request.duration", Value: 320, Type: tests.StringPtr("gauge"), Unit: tests.StringPtr("ms")}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			metricset, err := DecodeStatsD(test.line, base)
+			require.NoError(t, err)
+			assert.Equal(t, &Metricset{
+				Metadata:  base.Metadata,
+				Samples:   []*Sample{&test.sample},
+				Timestamp: requestTime,
+			}, metricset)
+		})
+	}
+
+	for statsdType, sampleType := range statsdTypes {
+		assert.True(t, sampleTypes[sampleType], "statsd type %q maps to unknown sample type %q", statsdType, sampleType)
+	}
+
+	for _, line := range []string{"", "requests", ":3|c", "requests:3", "requests:3|h", "requests:abc|c", "requests:3|c|@0.1"} {
+		_, err := DecodeStatsD(line, base)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestDecodeEventFromReader(t *testing.T) {
+	data, err := loader.LoadDataAsBytes("../testdata/intake-v2/metricsets.ndjson")
+	require.NoError(t, err)
+	meta := metadata.Metadata{Service: &metadata.Service{Name: tests.StringPtr("myservice")}}
+
+	var decoded int
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var event map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(line, &event))
+		raw, ok := event["metricset"]
+		if !ok {
+			continue
+		}
+		decoded++
+		assertDecodeEventFromReader(t, string(raw), meta)
+	}
+	assert.NotZero(t, decoded)
+
+	for _, input := range []string{
+		`{"samples":{}}`,
+		`{"samples":{"a":{"value":1},"b":null,"c":{"value":"2"}},"tags":{"d":"e"}}`,
+		`{"samples":{"a":{"value":1},"a":{"value":2},"b":{"value":3},"b":null}}`,
+		`{"samples":{"a":{"value":1}},"samples":{"b":{"value":2}}}`,
+	} {
+		assertDecodeEventFromReader(t, input, meta)
+	}
+}
+
+func TestDecodeEventFromReaderInvalid(t *testing.T) {
+	for _, input := range []string{
+		`null`,
+		`[]`,
+		`{}`,
+		`{"samples":null}`,
+		`{"samples":[]}`,
+		`{"samples":{"a":1}}`,
+		`{"samples":{"a":{"value":1},"b":{"type":"x"}}}`,
+		`{"samples":{"a":{"value":"x"}},"transaction":1}`,
+	} {
+		t.Run(input, func(t *testing.T) {
+			var raw interface{}
+			require.NoError(t, decoder.NewJSONDecoder(strings.NewReader(input)).Decode(&raw))
+			_, expected := DecodeEvent(model.Input{Raw: raw})
+			require.Error(t, expected)
+
+			_, err := DecodeEventFromReader(strings.NewReader(input), model.Config{}, metadata.Metadata{})
+			assert.Equal(t, expected, err)
+		})
+	}
+
+	_, err := DecodeEventFromReader(strings.NewReader(`{"samples":{"a":`), model.Config{}, metadata.Metadata{})
+	assert.Error(t, err)
+}
+
+// assertDecodeEventFromReader decodes input with DecodeEventFromReader,
+// and cross-checks the result with the map-based DecodeEvent.
+func assertDecodeEventFromReader(t *testing.T, input string, meta metadata.Metadata) {
+	fromReader, err := DecodeEventFromReader(strings.NewReader(input), model.Config{}, meta)
+	require.NoError(t, err)
+
+	raw, err := decoder.DecodeJSONData(strings.NewReader(input))
+	require.NoError(t, err)
+	fromMap, err := DecodeEvent(model.Input{
+		Raw:         raw,
+		RequestTime: fromReader.(*Metricset).Timestamp,
+		Metadata:    meta,
+	})
+	require.NoError(t, err)
+	assertMetricsetsMatch(t, *fromMap.(*Metricset), *fromReader.(*Metricset))
+}
+
 func TestTransform(t *testing.T) {
 	timestamp := time.Now()
+	ingested := timestamp.UTC().Format(time.RFC3339Nano)
+	versionedMetadata := metadata.Metadata{
+		Service: &metadata.Service{Name: tests.StringPtr("myservice"), Version: tests.StringPtr("1.0.0")},
+	}
 	metadata := metadata.Metadata{
 		Service: &metadata.Service{Name: tests.StringPtr("myservice")},
 	}
@@ -215,6 +963,7 @@ func TestTransform(t *testing.T) {
 			Output: []common.MapStr{
 				{
 					"processor": common.MapStr{"event": "metric", "name": "metric"},
+					"event":     common.MapStr{"ingested": ingested},
 					"service": common.MapStr{
 						"name": "myservice",
 					},
@@ -252,15 +1001,137 @@ func TestTransform(t *testing.T) {
 					"a":           common.MapStr{"counter": float64(612)},
 					"some":        common.MapStr{"gauge": float64(9.16)},
 					"processor":   common.MapStr{"event": "metric", "name": "metric"},
+					"event":       common.MapStr{"ingested": ingested},
 					"transaction": common.MapStr{"name": trName, "type": trType},
 					"span":        common.MapStr{"type": spType, "subtype": spSubtype},
 				},
 			},
 			Msg: "Payload with valid metric.",
 		},
+		{
+			Metricset: &Metricset{
+				Metadata:  metadata,
+				Timestamp: timestamp,
+				Span: &Span{
+					Type:               &spType,
+					Subtype:            &spSubtype,
+					DestinationService: &DestinationService{Resource: tests.StringPtr("mysql")},
+				},
+			},
+			Output: []common.MapStr{
+				{
+					"processor": common.MapStr{"event": "metric", "name": "metric"},
+					"event":     common.MapStr{"ingested": ingested},
+					"service":   common.MapStr{"name": "myservice"},
+					"span": common.MapStr{
+						"type":        spType,
+						"subtype":     spSubtype,
+						"destination": common.MapStr{"service": common.MapStr{"resource": "mysql"}},
+					},
+				},
+			},
+			Msg: "Payload with span destination service resource.",
+		},
+		{
+			Metricset: &Metricset{
+				Metadata:      metadata,
+				Timestamp:     timestamp,
+				Span:          &Span{Type: &spType, Subtype: &spSubtype},
+				Transaction:   &Transaction{Type: &trType, Name: &trName},
+				ServiceTarget: &ServiceTarget{Type: tests.StringPtr("postgresql"), Name: tests.StringPtr("users")},
+			},
+			Output: []common.MapStr{
+				{
+					"processor": common.MapStr{"event": "metric", "name": "metric"},
+					"event":     common.MapStr{"ingested": ingested},
+					"service": common.MapStr{
+						"name":   "myservice",
+						"target": common.MapStr{"type": "postgresql", "name": "users"},
+					},
+					"transaction": common.MapStr{"name": trName, "type": trType},
+					"span":        common.MapStr{"type": spType, "subtype": spSubtype},
+				},
+			},
+			Msg: "Payload with service target.",
+		},
+		{
+			Metricset: &Metricset{
+				Timestamp:     timestamp,
+				ServiceTarget: &ServiceTarget{Type: tests.StringPtr("postgresql")},
+			},
+			Output: []common.MapStr{
+				{
+					"processor": common.MapStr{"event": "metric", "name": "metric"},
+					"event":     common.MapStr{"ingested": ingested},
+					"service":   common.MapStr{"target": common.MapStr{"type": "postgresql"}},
+				},
+			},
+			Msg: "Payload with service target type only.",
+		},
+		{
+			Metricset: &Metricset{
+				Timestamp:     timestamp,
+				ServiceTarget: &ServiceTarget{Type: tests.StringPtr("http"), Name: tests.StringPtr("backend")},
+				ServiceOrigin: &ServiceOrigin{Id: tests.StringPtr("abc123"), Name: tests.StringPtr("frontend"), Version: tests.StringPtr("1.2.0")},
+			},
+			Output: []common.MapStr{
+				{
+					"processor": common.MapStr{"event": "metric", "name": "metric"},
+					"event":     common.MapStr{"ingested": ingested},
+					"service": common.MapStr{
+						"target": common.MapStr{"type": "http", "name": "backend"},
+						"origin": common.MapStr{"id": "abc123", "name": "frontend", "version": "1.2.0"},
+					},
+				},
+			},
+			Msg: "Payload with service origin.",
+		},
+		{
+			Metricset: &Metricset{
+				Timestamp:     timestamp,
+				ServiceOrigin: &ServiceOrigin{Version: tests.StringPtr("1.2.0")},
+			},
+			Output: []common.MapStr{
+				{
+					"processor": common.MapStr{"event": "metric", "name": "metric"},
+					"event":     common.MapStr{"ingested": ingested},
+					"service":   common.MapStr{"origin": common.MapStr{"version": "1.2.0"}},
+				},
+			},
+			Msg: "Payload with service origin version only.",
+		},
+		{
+			Metricset: &Metricset{
+				Timestamp: timestamp,
+				Metadata:  versionedMetadata,
+			},
+			Output: []common.MapStr{
+				{
+					"processor": common.MapStr{"event": "metric", "name": "metric"},
+					"event":     common.MapStr{"ingested": ingested},
+					"service":   common.MapStr{"name": "myservice", "version": "1.0.0"},
+				},
+			},
+			Msg: "Payload with service version from metadata.",
+		},
+		{
+			Metricset: &Metricset{
+				Timestamp:      timestamp,
+				Metadata:       versionedMetadata,
+				ServiceVersion: tests.StringPtr("1.1.0"),
+			},
+			Output: []common.MapStr{
+				{
+					"processor": common.MapStr{"event": "metric", "name": "metric"},
+					"event":     common.MapStr{"ingested": ingested},
+					"service":   common.MapStr{"name": "myservice", "version": "1.1.0"},
+				},
+			},
+			Msg: "Payload with service version override.",
+		},
 	}
 
-	tctx := &transform.Context{}
+	tctx := &transform.Context{Now: func() time.Time { return timestamp }}
 	for idx, test := range tests {
 		outputEvents := test.Metricset.Transform(context.Background(), tctx)
 
@@ -270,3 +1141,274 @@ func TestTransform(t *testing.T) {
 		}
 	}
 }
+
+func TestTransformSamplesAsArray(t *testing.T) {
+	metricset := &Metricset{
+		Samples: []*Sample{
+			{Name: "a.counter", Value: 612},
+			{Name: "some.gauge", Value: 9.16},
+		},
+	}
+	metricset.SamplesAsArray = true
+	outputEvents := metricset.Transform(context.Background(), &transform.Context{})
+	require.Len(t, outputEvents, 1)
+
+	fields := outputEvents[0].Fields
+	assert.Equal(t, common.MapStr{
+		"samples": []common.MapStr{
+			{"name": "a.counter", "value": float64(612)},
+			{"name": "some.gauge", "value": 9.16},
+		},
+	}, fields["metricset"])
+	assert.NotContains(t, fields, "a")
+	assert.NotContains(t, fields, "some")
+}
+
+func TestTransformHistogram(t *testing.T) {
+	metricset := &Metricset{
+		Samples: []*Sample{
+			{Name: "latency.histogram", Values: []float64{1.5, 2.5}, Counts: []int64{2, 7}},
+			{Name: "a.counter", Value: 612},
+		},
+	}
+
+	outputEvents := metricset.Transform(context.Background(), &transform.Context{})
+	require.Len(t, outputEvents, 1)
+	fields := outputEvents[0].Fields
+	assert.Equal(t, common.MapStr{"histogram": common.MapStr{
+		"values": []float64{1.5, 2.5},
+		"counts": []int64{2, 7},
+	}}, fields["latency"])
+	assert.Equal(t, common.MapStr{"counter": float64(612)}, fields["a"])
+
+	metricset.SamplesAsArray = true
+	outputEvents = metricset.Transform(context.Background(), &transform.Context{})
+	require.Len(t, outputEvents, 1)
+	assert.Equal(t, common.MapStr{
+		"samples": []common.MapStr{
+			{"name": "latency.histogram", "values": []float64{1.5, 2.5}, "counts": []int64{2, 7}},
+			{"name": "a.counter", "value": float64(612)},
+		},
+	}, outputEvents[0].Fields["metricset"])
+}
+
+func TestTransformSampleUnit(t *testing.T) {
+	metricset := &Metricset{
+		Samples: []*Sample{
+			{Name: "latency.avg", Value: 1.5, Unit: tests.StringPtr("ms")},
+			{Name: "latency.histogram", Values: []float64{1.5}, Counts: []int64{2}, Unit: tests.StringPtr("ms")},
+			{Name: "a.counter", Value: 612},
+		},
+	}
+
+	outputEvents := metricset.Transform(context.Background(), &transform.Context{})
+	require.Len(t, outputEvents, 1)
+	fields := outputEvents[0].Fields
+	// the unit is described apart from the value, which keeps its shape
+	assert.Equal(t, common.MapStr{
+		"avg": 1.5,
+		"histogram": common.MapStr{
+			"values": []float64{1.5},
+			"counts": []int64{2},
+		},
+	}, fields["latency"])
+	assert.Equal(t, common.MapStr{"counter": float64(612)}, fields["a"])
+	assert.Equal(t, common.MapStr{
+		"latency.avg":       common.MapStr{"unit": "ms"},
+		"latency.histogram": common.MapStr{"unit": "ms"},
+	}, fields["_metric_descriptions"])
+
+	metricset.SamplesAsArray = true
+	outputEvents = metricset.Transform(context.Background(), &transform.Context{})
+	require.Len(t, outputEvents, 1)
+	assert.Equal(t, common.MapStr{
+		"samples": []common.MapStr{
+			{"name": "latency.avg", "value": 1.5, "unit": "ms"},
+			{"name": "latency.histogram", "values": []float64{1.5}, "counts": []int64{2}, "unit": "ms"},
+			{"name": "a.counter", "value": float64(612)},
+		},
+	}, outputEvents[0].Fields["metricset"])
+}
+
+func TestTransformHoistCommonUnit(t *testing.T) {
+	uniform := &Metricset{
+		HoistCommonUnit: true,
+		Samples: []*Sample{
+			{Name: "memory.heap", Value: 1024, Unit: tests.StringPtr("byte")},
+			{Name: "memory.stack", Value: 512, Unit: tests.StringPtr("byte")},
+		},
+	}
+	outputEvents := uniform.Transform(context.Background(), &transform.Context{})
+	require.Len(t, outputEvents, 1)
+	fields := outputEvents[0].Fields
+	assert.Equal(t, common.MapStr{"heap": float64(1024), "stack": float64(512)}, fields["memory"])
+	assert.Equal(t, common.MapStr{"unit": "byte"}, fields["metricset"])
+	assert.NotContains(t, fields, "_metric_descriptions")
+
+	uniform.SamplesAsArray = true
+	outputEvents = uniform.Transform(context.Background(), &transform.Context{})
+	require.Len(t, outputEvents, 1)
+	assert.Equal(t, common.MapStr{
+		"unit": "byte",
+		"samples": []common.MapStr{
+			{"name": "memory.heap", "value": float64(1024)},
+			{"name": "memory.stack", "value": float64(512)},
+		},
+	}, outputEvents[0].Fields["metricset"])
+
+	mixed := &Metricset{
+		HoistCommonUnit: true,
+		Samples: []*Sample{
+			{Name: "memory.heap", Value: 1024, Unit: tests.StringPtr("byte")},
+			{Name: "latency.avg", Value: 1.5, Unit: tests.StringPtr("ms")},
+			{Name: "a.counter", Value: 612},
+		},
+	}
+	outputEvents = mixed.Transform(context.Background(), &transform.Context{})
+	require.Len(t, outputEvents, 1)
+	fields = outputEvents[0].Fields
+	assert.Equal(t, common.MapStr{"heap": float64(1024)}, fields["memory"])
+	assert.Equal(t, common.MapStr{"avg": 1.5}, fields["latency"])
+	assert.Equal(t, common.MapStr{
+		"memory.heap": common.MapStr{"unit": "byte"},
+		"latency.avg": common.MapStr{"unit": "ms"},
+	}, fields["_metric_descriptions"])
+	assert.NotContains(t, fields, "metricset")
+}
+
+func TestDecodeHoistCommonUnit(t *testing.T) {
+	transformable, err := DecodeEvent(model.Input{
+		Raw: map[string]interface{}{
+			"samples": map[string]interface{}{
+				"memory.heap":  map[string]interface{}{"value": json.Number("1024"), "unit": "byte"},
+				"memory.stack": map[string]interface{}{"value": json.Number("512"), "unit": "byte"},
+			},
+		},
+		Config: model.Config{HoistCommonUnit: true},
+	})
+	require.NoError(t, err)
+	output := transformable.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	assert.Equal(t, common.MapStr{"unit": "byte"}, output[0].Fields["metricset"])
+}
+
+func TestDecodeSamplesAsArray(t *testing.T) {
+	transformable, err := DecodeEvent(model.Input{
+		Raw: map[string]interface{}{
+			"samples": map[string]interface{}{"a.counter": map[string]interface{}{"value": json.Number("1.5")}},
+		},
+		Config: model.Config{SamplesAsArray: true},
+	})
+	require.NoError(t, err)
+	output := transformable.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	assert.Equal(t, common.MapStr{
+		"samples": []common.MapStr{{"name": "a.counter", "value": 1.5}},
+	}, output[0].Fields["metricset"])
+}
+
+func TestDecodeConvertUnitsToBase(t *testing.T) {
+	for name, test := range map[string]struct {
+		sample   map[string]interface{}
+		config   model.Config
+		expected Sample
+		err      string
+	}{
+		"kb": {
+			sample:   map[string]interface{}{"value": json.Number("2"), "unit": "kb"},
+			config:   model.Config{ConvertUnitsToBase: true},
+			expected: Sample{Name: "a", Value: 2048, IntValue: tests.Int64Ptr(2048), Unit: tests.StringPtr("byte")},
+		},
+		"mb fractional": {
+			sample:   map[string]interface{}{"value": json.Number("1.5"), "unit": "mb"},
+			config:   model.Config{ConvertUnitsToBase: true},
+			expected: Sample{Name: "a", Value: 1.5 * 1024 * 1024, Unit: tests.StringPtr("byte")},
+		},
+		"kb histogram": {
+			sample:   map[string]interface{}{"values": []interface{}{json.Number("1"), json.Number("4")}, "counts": []interface{}{json.Number("3"), json.Number("1")}, "unit": "kb"},
+			config:   model.Config{ConvertUnitsToBase: true},
+			expected: Sample{Name: "a", Values: []float64{1024, 4096}, Counts: []int64{3, 1}, Unit: tests.StringPtr("byte")},
+		},
+		"percent": {
+			sample:   map[string]interface{}{"value": json.Number("0.5"), "unit": "percent"},
+			config:   model.Config{ConvertUnitsToBase: true},
+			expected: Sample{Name: "a", Value: 0.5, Unit: tests.StringPtr("percent")},
+		},
+		"disabled": {
+			sample:   map[string]interface{}{"value": json.Number("2"), "unit": "kb"},
+			config:   model.Config{Experimental: true},
+			expected: Sample{Name: "a", Value: 2, IntValue: tests.Int64Ptr(2), Unit: tests.StringPtr("kb")},
+		},
+		"disabled, unknown unit": {
+			sample: map[string]interface{}{"value": json.Number("2"), "unit": "kb"},
+			err:    `invalid sample: a: unknown unit "kb"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw:    map[string]interface{}{"samples": map[string]interface{}{"a": test.sample}},
+				Config: test.config,
+			})
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []*Sample{&test.expected}, transformable.(*Metricset).Samples)
+		})
+	}
+}
+
+func BenchmarkDecodeMetricset(b *testing.B) {
+	samples := make(map[string]interface{}, 100)
+	for i := 0; i < 100; i++ {
+		samples[fmt.Sprintf("metric.%d", i)] = map[string]interface{}{"value": json.Number(fmt.Sprint(i))}
+	}
+	input := model.Input{Raw: map[string]interface{}{
+		"samples":   samples,
+		"timestamp": json.Number("1496170422281000"),
+	}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodeEvent(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMetricsetEstimatedSize(t *testing.T) {
+	unit := "ms"
+	metricset := &Metricset{
+		Samples: []*Sample{
+			{Name: "a.counter", Value: 1, Type: tests.StringPtr("counter")},
+			{Name: "latency", Unit: &unit, Values: []float64{1, 2}, Counts: []int64{3, 4}},
+		},
+		Transaction: &Transaction{Name: tests.StringPtr("GET /")},
+		Labels:      common.MapStr{"zone": "a"},
+		Metadata:    metadata.Metadata{Service: &metadata.Service{Name: tests.StringPtr("svc")}},
+	}
+	// timestamp + "a.counter" + "counter" + value + "latency" + "ms" + 4 histogram numbers + "GET /"
+	// + labels + service name
+	expected := 8 + 9 + 7 + 8 + 7 + 2 + 4*8 + 5 + 5 + 3
+	assert.Equal(t, expected, metricset.EstimatedSize())
+
+	// the estimate is not published with the event
+	output := metricset.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	assert.Nil(t, output[0].Meta)
+}
+
+func BenchmarkMetricsetEstimatedSize(b *testing.B) {
+	samples := make([]*Sample, 100)
+	for i := range samples {
+		samples[i] = &Sample{Name: fmt.Sprintf("sample.%d", i), Value: float64(i)}
+	}
+	metricset := &Metricset{Samples: samples}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		metricset.EstimatedSize()
+	}
+}
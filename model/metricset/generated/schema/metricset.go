@@ -22,16 +22,17 @@ const ModelSchema = `{
     "type": "object",
     "description": "Data captured by an agent representing an event occurring in a monitored service",
     "allOf": [
-        {     "$id": "doc/spec/timestamp_epoch.json",
-    "title": "Timestamp Epoch",
-    "description": "Object with 'timestamp' property.",
+        {     "$id": "doc/spec/timestamp_epoch_or_offset.json",
+    "title": "Timestamp Epoch or Offset",
+    "description": "Object with 'timestamp' property, which may be relative to the request time.",
     "type": ["object"],
-    "properties": {  
+    "properties": {
         "timestamp": {
-            "description": "Recorded time of the event, UTC based and formatted as microseconds since Unix epoch",
-            "type": ["integer", "null"]
+            "description": "Recorded time of the event, UTC based and formatted as microseconds since Unix epoch, or as a signed offset in microseconds relative to the time the request was received, e.g. \"-1500\"",
+            "type": ["integer", "string", "null"],
+            "pattern": "^[+-][0-9]+$"
         }
-    }},
+    } },
         {     "$id": "docs/spec/span_type.json",
     "title": "Span Type",
     "type": ["object"],
@@ -86,9 +87,48 @@ const ModelSchema = `{
     "type": ["object", "null"],
     "description": "A single metric sample.",
     "properties": {
-        "value": {"type": "number"}
+        "value": {
+            "type": ["number", "string"],
+            "description": "The value of the sample. Agents may send string-encoded numbers, which are parsed on decoding."
+        },
+        "values": {
+            "type": "array",
+            "items": {"type": "number"},
+            "description": "The bucket values of a pre-aggregated histogram, sent instead of value."
+        },
+        "counts": {
+            "type": "array",
+            "items": {"type": "integer", "minimum": 0},
+            "description": "The number of observations in each bucket of a pre-aggregated histogram, one for each of values."
+        },
+        "type": {
+            "type": ["string", "null"],
+            "enum": ["gauge", "counter", "summary", null],
+            "description": "The kind of metric."
+        },
+        "sum": {
+            "type": "number",
+            "description": "The sum of all observations of a pre-aggregated summary, sent with type summary instead of value."
+        },
+        "count": {
+            "type": "integer",
+            "minimum": 0,
+            "description": "The number of observations of a pre-aggregated summary, sent with type summary instead of value."
+        },
+        "unit": {
+            "type": ["string", "null"],
+            "maxLength": 1024,
+            "description": "The unit of the sample value, one of \"byte\", \"percent\", \"ns\", \"us\", \"ms\" and \"s\". Other units are only accepted in experimental mode."
+        }
     },
-    "required": ["value"]
+    "anyOf": [
+        {"required": ["value"]},
+        {"required": ["values", "counts"]},
+        {
+            "properties": {"type": {"enum": ["summary"]}},
+            "required": ["type", "sum", "count"]
+        }
+    ]
                         }
                     },
                     "additionalProperties": false
@@ -338,7 +338,7 @@ func DecodeEvent(input m.Input) (transform.Transformable, error) {
 			event.Service = service
 		}
 
-		if event.Message, err = m.DecodeMessage(ctx, decoder.Err); err != nil {
+		if event.Message, err = m.DecodeMessage(ctx, input.Config.CanonicalizeMessageHeaders, decoder.Err); err != nil {
 			return nil, err
 		}
 
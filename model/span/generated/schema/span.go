@@ -273,7 +273,9 @@ const ModelSchema = `{
     "type": ["object", "null"],
     "properties": {
         "queue": {
-            "type": ["object", "null"],
+            "description": "The message queue, either as an object or, for agents sending it flat, as the queue name.",
+            "type": ["object", "string", "null"],
+            "maxLength": 1024,
             "properties": {
                 "name": {
                     "description": "Name of the message queue where the message is received.",
@@ -282,6 +284,16 @@ const ModelSchema = `{
                 }
             }
         },
+        "routing_key": {
+            "description": "Routing key of the message, for brokers that route messages by key, e.g. RabbitMQ.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "exchange": {
+            "description": "Name of the exchange the message is published to, e.g. a RabbitMQ exchange.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
         "age": {
             "type": ["object", "null"],
             "properties": {
@@ -19,7 +19,17 @@ package transaction
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/elastic/apm-server/model/field"
 
@@ -30,8 +40,10 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/monitoring"
 
+	"github.com/elastic/apm-server/decoder"
 	m "github.com/elastic/apm-server/model"
 	"github.com/elastic/apm-server/model/metadata"
+	"github.com/elastic/apm-server/model/metricset"
 	"github.com/elastic/apm-server/model/transaction/generated/schema"
 	"github.com/elastic/apm-server/transform"
 	"github.com/elastic/apm-server/utility"
@@ -42,6 +54,27 @@ const (
 	processorName      = "transaction"
 	transactionDocType = "transaction"
 	emptyString        = ""
+
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+	outcomeUnknown = "unknown"
+
+	traceIDLength = 32
+	spanIDLength  = 16
+
+	// maxTracestateLength is the maximum length of a W3C tracestate header.
+	maxTracestateLength = 512
+
+	// defaultMaxStackTraceIDs is the default maximum number of
+	// profiler stack trace IDs retained per transaction.
+	defaultMaxStackTraceIDs = 1000
+
+	// defaultMaxTypeLength is the default maximum length of
+	// transaction types, in characters.
+	defaultMaxTypeLength = 1024
+
+	// truncatedMarker is appended to truncated values.
+	truncatedMarker = "…"
 )
 
 var (
@@ -51,8 +84,31 @@ var (
 	cachedModelSchema = validation.CreateSchema(schema.ModelSchema, "transaction")
 	RUMV3Schema       = validation.CreateSchema(schema.RUMV3Schema, "transaction")
 
-	errMissingInput = errors.New("input missing for decoding transaction event")
-	errInvalidType  = errors.New("invalid type for transaction event")
+	// markProcessorEntry is used for zero duration transactions emitted as spans.
+	markProcessorEntry = common.MapStr{"name": processorName, "event": "span"}
+
+	// droppedOTelAttributes counts OpenTelemetry attributes dropped
+	// during decoding due to unsupported value types.
+	droppedOTelAttributes = monitoring.NewInt(Metrics, "otel.attributes.dropped")
+
+	// droppedFutureTimestamps counts transactions dropped when transformed
+	// for having a timestamp more than MaxFutureSkew ahead.
+	droppedFutureTimestamps = monitoring.NewInt(Metrics, "timestamps.future.dropped")
+
+	errMissingInput   = errors.New("input missing for decoding transaction event")
+	errInvalidType    = errors.New("invalid type for transaction event")
+	errInvalidOutcome = errors.New("invalid outcome for transaction event")
+
+	// semverRegexp matches semantic versions as specified at https://semver.org
+	semverRegexp = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+		`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+		`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+	// emailRegexp is a deliberately loose check for "local@domain.tld"
+	emailRegexp = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+	// whitespaceRegexp matches runs of whitespace
+	whitespaceRegexp = regexp.MustCompile(`\s+`)
 )
 
 func ModelSchema() *jsonschema.Schema {
@@ -71,6 +127,8 @@ type Event struct {
 	Type      string
 	Name      *string
 	Result    *string
+	Outcome   *string
+	Category  *string
 	Duration  float64
 	Marks     common.MapStr
 	Message   *m.Message
@@ -85,7 +143,122 @@ type Event struct {
 	Service   *metadata.Service
 	Client    *m.Client
 
+	// SampleRate holds the sampling rate applied by the agent, and
+	// RepresentativeCount the number of transactions this one represents.
+	SampleRate          *float64
+	RepresentativeCount float64
+
 	Experimental interface{}
+
+	Links []SpanLink
+
+	DroppedSpansStats []DroppedSpanStats
+
+	// EventCategory holds the ECS event categories mapped from Type.
+	EventCategory []string
+
+	// EventDuration holds the duration in nanoseconds, to be emitted
+	// as the ECS event.duration.
+	EventDuration *int64
+
+	// EventDropped holds the number of dropped spans, to be emitted
+	// as event.dropped.
+	EventDropped *int
+
+	// EventReference holds the parent id, to be emitted as event.reference.
+	EventReference *string
+
+	// HumanDuration holds the duration formatted for display,
+	// to be emitted as transaction.duration.human.
+	HumanDuration string
+
+	// DurationSummary holds the duration as a summary weighted by the
+	// representative count, to be emitted alongside transaction.duration.us.
+	DurationSummary *DurationSummary
+
+	// SampledAsInt controls whether sampled is emitted as 1 or 0.
+	SampledAsInt bool
+
+	// EmitEventCreated controls whether event.created is emitted, holding
+	// EventCreated or, if nil, the time at which the event is transformed.
+	EmitEventCreated bool
+
+	// EventCreated holds the timestamp recorded by the agent, if any.
+	EventCreated *time.Time
+
+	// MarkZeroDurationAsSpan controls whether a zero duration transaction
+	// is emitted with processor.event "span".
+	MarkZeroDurationAsSpan bool
+
+	// GeoResolver, if non-nil, resolves the client IP to geographic
+	// information, taking precedence over transform.Context.GeoLookup.
+	GeoResolver m.GeoResolver
+
+	// EmitRefererDomain controls whether the domain of the page referer
+	// is emitted as http.request.referrer.domain.
+	EmitRefererDomain bool
+
+	// CoerceBooleanLabels controls whether "true" and "false" label
+	// values are emitted as booleans.
+	CoerceBooleanLabels bool
+
+	// StringifyLabels controls whether boolean and numeric label values
+	// are emitted as strings.
+	StringifyLabels bool
+
+	// HTTPResult holds the class of the HTTP response status code, e.g.
+	// "HTTP 2xx", to be emitted as the result in place of Result.
+	HTTPResult *string
+
+	FAAS *FAAS
+
+	// Cloud holds event-level cloud information, overriding the
+	// metadata cloud information field by field.
+	Cloud *metadata.Cloud
+
+	// MaxFutureSkew, if positive, is the maximum duration by which
+	// Timestamp may be ahead of the time at which the event is transformed.
+	// Later events are dropped, unless ClampFutureTimestamps is set.
+	MaxFutureSkew time.Duration
+
+	// ClampFutureTimestamps controls whether a Timestamp exceeding
+	// MaxFutureSkew is replaced by the transformation time when
+	// transforming, rather than the event being dropped.
+	ClampFutureTimestamps bool
+
+	// Tracestate holds the W3C tracestate propagated with the transaction.
+	// TracestateTruncated is set if it exceeded the maximum length and list
+	// members were dropped.
+	Tracestate          *string
+	TracestateTruncated bool
+
+	// OTel holds information passed through by OpenTelemetry bridges.
+	OTel *OTel
+
+	// TypeTruncated is set if Type exceeded the maximum length,
+	// and was truncated.
+	TypeTruncated bool
+
+	// ProfilerStackTraceIDs holds the IDs of profiler stack traces
+	// recorded during the transaction, for correlating with profiles.
+	ProfilerStackTraceIDs []string
+}
+
+// FAAS holds information about the serverless function invocation
+// a transaction was recorded for.
+type FAAS struct {
+	ID               *string
+	Coldstart        *bool
+	Execution        *string
+	TriggerType      *string
+	TriggerRequestID *string
+}
+
+// DurationSummary holds the number of transactions and the sum of their
+// durations in microseconds.
+type DurationSummary struct {
+	Count int64
+	SumUs int64
 }
 
 type SpanCount struct {
@@ -93,9 +266,24 @@ type SpanCount struct {
 	Started *int
 }
 
+// DroppedSpanStats holds aggregated information about spans dropped by the
+// agent, for a given destination service resource and outcome.
+type DroppedSpanStats struct {
+	DestinationServiceResource string
+	Outcome                    string
+	DurationCount              int
+	DurationSumUs              int64
+}
+
+// SpanLink holds a causal relationship between a transaction and a span of another trace.
+type SpanLink struct {
+	TraceId string
+	SpanId  string
+}
+
 func DecodeRUMV3Event(input m.Input) (transform.Transformable, error) {
 	transformable, err := DecodeEvent(input)
-	if err != nil {
+	if err != nil || transformable == nil {
 		return transformable, err
 	}
 	event, _ := transformable.(*Event)
@@ -147,6 +335,84 @@ func decodeRUMV3Marks(event *Event, raw map[string]interface{}, cfg m.Config) (t
 	return event, decoder.Err
 }
 
+// DecodeEventFromReader decodes a single JSON encoded transaction event from r,
+// using cfg and adding meta to the event. The request time is taken to be
+// the current time.
+//
+// The event is read token by token: context.experimental is skipped unless
+// cfg.Experimental is set, and profiler stack trace IDs beyond the maximum
+// are discarded as they are read, so that neither is held in memory. The
+// remaining fields are decoded as in DecodeEvent.
+func DecodeEventFromReader(r io.Reader, cfg m.Config, meta metadata.Metadata) (transform.Transformable, error) {
+	d := decoder.NewJSONDecoder(r)
+	contextKey := field.Mapper(cfg.HasShortFieldNames)("context")
+	raw := make(map[string]interface{})
+	value, ok, err := decoder.DecodeObject(d, func(key string) error {
+		var value interface{}
+		var err error
+		switch key {
+		case contextKey:
+			value, err = readContext(d, cfg.Experimental)
+		case "profiler_stack_trace_ids":
+			value, err = readProfilerStackTraceIDs(d, maxStackTraceIDs(cfg))
+		default:
+			err = d.Decode(&value)
+		}
+		raw[key] = value
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		value = raw
+	}
+	return DecodeEvent(m.Input{
+		Raw:         value,
+		RequestTime: time.Now(),
+		Metadata:    meta,
+		Config:      cfg,
+	})
+}
+
+// readContext reads the context of a transaction from d, skipping
+// context.experimental unless experimental is true.
+func readContext(d *json.Decoder, experimental bool) (interface{}, error) {
+	ctx := make(map[string]interface{})
+	value, ok, err := decoder.DecodeObject(d, func(key string) error {
+		if key == "experimental" && !experimental {
+			return decoder.SkipValue(d)
+		}
+		var value interface{}
+		err := d.Decode(&value)
+		ctx[key] = value
+		return err
+	})
+	if err != nil || !ok {
+		return value, err
+	}
+	return ctx, nil
+}
+
+// readProfilerStackTraceIDs reads the profiler stack trace IDs of a
+// transaction from d, skipping all but the first max IDs.
+func readProfilerStackTraceIDs(d *json.Decoder, max int) (interface{}, error) {
+	ids := []interface{}{}
+	value, ok, err := decoder.DecodeArray(d, func() error {
+		if len(ids) == max {
+			return decoder.SkipValue(d)
+		}
+		var id interface{}
+		err := d.Decode(&id)
+		ids = append(ids, id)
+		return err
+	})
+	if err != nil || !ok {
+		return value, err
+	}
+	return ids, nil
+}
+
 func DecodeEvent(input m.Input) (transform.Transformable, error) {
 	if input.Raw == nil {
 		return nil, errMissingInput
@@ -156,11 +422,20 @@ func DecodeEvent(input m.Input) (transform.Transformable, error) {
 		return nil, errInvalidType
 	}
 
-	ctx, err := m.DecodeContext(raw, input.Config, nil)
-	if err != nil {
-		return nil, err
+	ctx, ctxErr := m.DecodeContext(raw, input.Config, nil)
+	if ctxErr != nil {
+		if !input.Config.CollectAllErrors {
+			return nil, ctxErr
+		}
+		ctx = &m.Context{}
 	}
-	decoder := utility.ManualDecoder{}
+	if max := input.Config.MaxRequestBodyBytes; max > 0 && ctx.Http != nil && ctx.Http.Request != nil {
+		ctx.Http.Request.TruncateBody(max)
+	}
+	if input.Config.SanitizeCustomKeys {
+		ctx.Custom = ctx.Custom.SanitizeKeys()
+	}
+	decoder := utility.ManualDecoder{CollectErrors: input.Config.CollectAllErrors}
 	fieldName := field.Mapper(input.Config.HasShortFieldNames)
 	e := Event{
 		Metadata:     input.Metadata,
@@ -168,7 +443,9 @@ func DecodeEvent(input m.Input) (transform.Transformable, error) {
 		Type:         decoder.String(raw, fieldName("type")),
 		Name:         decoder.StringPtr(raw, fieldName("name")),
 		Result:       decoder.StringPtr(raw, fieldName("result")),
+		Outcome:      decoder.StringPtr(raw, fieldName("outcome")),
 		Duration:     decoder.Float64(raw, fieldName("duration")),
+		SampleRate:   decoder.Float64Ptr(raw, "sample_rate"),
 		Labels:       ctx.Labels,
 		Page:         ctx.Page,
 		Http:         ctx.Http,
@@ -181,16 +458,237 @@ func DecodeEvent(input m.Input) (transform.Transformable, error) {
 		Message:      ctx.Message,
 		Sampled:      decoder.BoolPtr(raw, fieldName("sampled")),
 		Marks:        decoder.MapStr(raw, fieldName("marks")),
-		Timestamp:    decoder.TimeEpochMicro(raw, fieldName("timestamp")),
-		SpanCount: SpanCount{
-			Dropped: decoder.IntPtr(raw, fieldName("dropped"), fieldName("span_count")),
-			Started: decoder.IntPtr(raw, fieldName("started"), fieldName("span_count"))},
-		ParentId: decoder.StringPtr(raw, "parent_id"),
-		TraceId:  decoder.String(raw, "trace_id"),
+		ParentId:     decoder.StringPtr(raw, "parent_id"),
+		TraceId:      decoder.String(raw, "trace_id"),
+		Tracestate:   decoder.StringPtr(raw, "tracestate"),
+	}
+	timestamp, err := input.DecodeTimestamp(raw, fieldName("timestamp"))
+	if input.Config.CollectAllErrors {
+		var errs utility.Errors
+		if ctxErr != nil {
+			errs = append(errs, &utility.FieldError{Field: fieldName("context"), Err: ctxErr})
+		}
+		errs = append(errs, decoder.Errs...)
+		if err != nil {
+			errs = append(errs, &utility.FieldError{Field: fieldName("timestamp"), Err: err})
+		}
+		if len(errs) > 0 {
+			return nil, errs
+		}
 	}
 	if decoder.Err != nil {
 		return nil, decoder.Err
 	}
+	if err != nil {
+		return nil, err
+	}
+	e.Timestamp = timestamp
+	if name := input.Config.UnknownServiceName; name != "" && e.serviceName() == "" {
+		if e.Service == nil {
+			e.Service = &metadata.Service{}
+		}
+		e.Service.Name = &name
+	}
+	if isExcludedService(e.serviceName(), input.Config.ExcludeServices) {
+		return nil, nil
+	}
+	if e.Sampled == nil {
+		if sampled, ok := input.Config.DefaultSampledByService[e.serviceName()]; ok {
+			e.Sampled = &sampled
+		}
+	}
+	maxTypeLength := input.Config.MaxTypeLength
+	if maxTypeLength <= 0 {
+		maxTypeLength = defaultMaxTypeLength
+	}
+	if utf8.RuneCountInString(e.Type) > maxTypeLength {
+		if !input.Config.TruncateOverlong {
+			return nil, errors.Errorf("invalid type for transaction event: exceeds maximum length of %d characters", maxTypeLength)
+		}
+		e.Type = truncate(e.Type, maxTypeLength-utf8.RuneCountInString(truncatedMarker)) + truncatedMarker
+		e.TypeTruncated = true
+	}
+	if e.SpanCount, err = decodeSpanCount(raw, fieldName); err != nil {
+		return nil, err
+	}
+	if e.SampleRate != nil {
+		if *e.SampleRate <= 0 {
+			return nil, errors.Errorf("invalid sample_rate %v for transaction event, must be greater than zero", *e.SampleRate)
+		}
+		e.RepresentativeCount = 1 / *e.SampleRate
+	}
+	links := decoder.InterfaceArr(raw, "links")
+	if e.Links, err = decodeLinks(links, decoder.Err); err != nil {
+		return nil, err
+	}
+	if e.FAAS, err = decodeFAAS(raw); err != nil {
+		return nil, err
+	}
+	if context, ok := raw["context"].(map[string]interface{}); ok {
+		if e.Cloud, err = metadata.DecodeCloud(context["cloud"], nil); err != nil {
+			return nil, err
+		}
+	}
+	otel := decoder.MapStr(raw, "otel")
+	if e.OTel, err = decodeOTel(otel, decoder.Err); err != nil {
+		return nil, err
+	}
+	if method := e.OTel.httpMethod(); method != "" {
+		// OpenTelemetry bridges record the request method as an attribute;
+		// it is lowercased like methods decoded from the context
+		if e.Http == nil {
+			e.Http = &m.Http{}
+		}
+		if e.Http.Request == nil {
+			e.Http.Request = &m.Req{}
+		}
+		if e.Http.Request.Method == "" {
+			e.Http.Request.Method = strings.ToLower(method)
+		}
+	}
+	if e.Tracestate == nil {
+		e.Tracestate = decoder.StringPtr(raw, "trace_state", "context")
+	}
+	if e.Tracestate != nil && len(*e.Tracestate) > maxTracestateLength {
+		e.Tracestate = truncateTracestate(*e.Tracestate)
+		e.TracestateTruncated = true
+	}
+	stackTraceIDs := decoder.InterfaceArr(raw, "profiler_stack_trace_ids")
+	if e.ProfilerStackTraceIDs, err = decodeProfilerStackTraceIDs(stackTraceIDs, maxStackTraceIDs(input.Config), decoder.Err); err != nil {
+		return nil, err
+	}
+	droppedSpansStats := decoder.InterfaceArr(raw, "dropped_spans_stats")
+	if e.DroppedSpansStats, err = decodeDroppedSpansStats(droppedSpansStats, decoder.Err); err != nil {
+		return nil, err
+	}
+	if e.Message != nil && e.Message.AgeMillis != nil && *e.Message.AgeMillis < 0 {
+		return nil, errors.Errorf("invalid message age %d for transaction event, must not be negative", *e.Message.AgeMillis)
+	}
+	if max := input.Config.MaxRequestHeaders; max > 0 && e.Http != nil && e.Http.Request != nil {
+		e.Http.Request.Headers = limitHeaders(e.Http.Request.Headers, max)
+	}
+	if input.Config.ValidateServiceVersion && e.Service != nil && e.Service.Version != nil {
+		if !semverRegexp.MatchString(*e.Service.Version) {
+			return nil, errors.Errorf("invalid service version %q, expected semantic version", *e.Service.Version)
+		}
+	}
+	if e.Outcome != nil {
+		switch *e.Outcome {
+		case outcomeSuccess, outcomeFailure, outcomeUnknown:
+		default:
+			return nil, errInvalidOutcome
+		}
+	} else if e.Http != nil && e.Http.Response != nil && e.Http.Response.StatusCode != nil {
+		// derive the outcome from the HTTP status code for agents
+		// not sending it explicitly
+		outcome := outcomeSuccess
+		if *e.Http.Response.StatusCode >= 500 {
+			outcome = outcomeFailure
+		}
+		e.Outcome = &outcome
+	}
+	// collapse and trim first, so that strings left empty are dropped
+	if input.Config.CollapseNameWhitespace && e.Name != nil {
+		name := whitespaceRegexp.ReplaceAllLiteralString(*e.Name, " ")
+		e.Name = &name
+	}
+	if input.Config.TrimResult && e.Result != nil {
+		result := strings.TrimSpace(*e.Result)
+		e.Result = &result
+	}
+	if input.Config.DropEmptyStrings {
+		e.Name = nilIfEmpty(e.Name)
+		e.Result = nilIfEmpty(e.Result)
+		e.ParentId = nilIfEmpty(e.ParentId)
+	}
+	if input.Config.StrictIDs {
+		if err := validateID("trace_id", e.TraceId, traceIDLength); err != nil {
+			return nil, err
+		}
+		if e.ParentId != nil {
+			if err := validateID("parent_id", *e.ParentId, spanIDLength); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if input.Config.MaxUserNameLength > 0 && e.User != nil && e.User.Name != nil {
+		name := truncate(*e.User.Name, input.Config.MaxUserNameLength)
+		e.User.Name = &name
+	}
+	if input.Config.ValidateUserEmail && e.User != nil && e.User.Email != nil {
+		if !emailRegexp.MatchString(*e.User.Email) {
+			e.User.Email = nil
+		}
+	}
+	if categories, ok := input.Config.TypeToCategory[e.Type]; ok && len(categories) > 0 {
+		e.EventCategory = categories
+	}
+	if input.Config.EmitECSDuration {
+		duration := utility.Float64ToInt64(math.Round(e.Duration * 1e6))
+		e.EventDuration = &duration
+	}
+	if input.Config.EmitEventReference {
+		e.EventReference = e.ParentId
+	}
+	if input.Config.EmitDurationSummary {
+		weight := 1.0
+		if e.SampleRate != nil {
+			weight = e.RepresentativeCount
+		}
+		e.DurationSummary = &DurationSummary{
+			Count: int64(math.Round(weight)),
+			SumUs: utility.Float64ToInt64(math.Round(e.Duration * 1000 * weight)),
+		}
+	}
+	if input.Config.EmitHumanDuration {
+		e.HumanDuration = time.Duration(utility.Float64ToInt64(math.Round(e.Duration * 1e6))).String()
+	}
+	e.SampledAsInt = input.Config.SampledAsInt
+	e.MarkZeroDurationAsSpan = input.Config.MarkZeroDurationAsSpan
+	e.GeoResolver = input.Config.GeoResolver
+	e.MaxFutureSkew = input.Config.MaxFutureSkew
+	e.ClampFutureTimestamps = input.Config.ClampFutureTimestamps
+	e.EmitRefererDomain = input.Config.EmitRefererDomain
+	e.CoerceBooleanLabels = input.Config.CoerceBooleanLabels
+	e.StringifyLabels = input.Config.StringifyLabels
+	if input.Config.OmitZeroSpanCount {
+		if e.SpanCount.Dropped != nil && *e.SpanCount.Dropped == 0 {
+			e.SpanCount.Dropped = nil
+		}
+		if e.SpanCount.Started != nil && *e.SpanCount.Started == 0 {
+			e.SpanCount.Started = nil
+		}
+	}
+	// defaulted after omitting zero counts, so the default is kept
+	if input.Config.DefaultSpanCount && e.SpanCount.Started == nil && (e.Sampled == nil || *e.Sampled) {
+		started := 0
+		e.SpanCount.Started = &started
+	}
+	// copied after omitting zero counts, so event.dropped agrees with span_count.dropped
+	if input.Config.EmitEventDropped && e.SpanCount.Dropped != nil {
+		dropped := *e.SpanCount.Dropped
+		e.EventDropped = &dropped
+	}
+	if input.Config.NormalizeHTTPResult && e.Http != nil && e.Http.Response != nil && e.Http.Response.StatusCode != nil {
+		if code := *e.Http.Response.StatusCode; code >= 100 && code < 600 {
+			result := fmt.Sprintf("HTTP %dxx", code/100)
+			e.HTTPResult = &result
+		}
+	}
+	if input.Config.EmitTransactionCategory {
+		category := e.Type
+		if e.Result != nil && *e.Result != "" {
+			category += ":" + resultBucket(*e.Result)
+		}
+		e.Category = &category
+	}
+	if input.Config.EmitEventCreated {
+		e.EmitEventCreated = true
+		if !e.Timestamp.IsZero() {
+			created := e.Timestamp
+			e.EventCreated = &created
+		}
+	}
 	if e.Timestamp.IsZero() {
 		e.Timestamp = input.RequestTime
 	}
@@ -198,21 +696,460 @@ func DecodeEvent(input m.Input) (transform.Transformable, error) {
 	return &e, nil
 }
 
+// OTel holds information passed through by OpenTelemetry bridges.
+type OTel struct {
+	SpanKind   string
+	Attributes map[string]interface{}
+}
+
+// decodeOTel decodes the otel object. Attributes with values other than
+// strings, booleans and numbers, e.g. nested objects, are dropped.
+func decodeOTel(input map[string]interface{}, err error) (*OTel, error) {
+	if err != nil || input == nil {
+		return nil, err
+	}
+	decoder := utility.ManualDecoder{}
+	otel := OTel{SpanKind: decoder.String(input, "span_kind")}
+	attributes := decoder.MapStr(input, "attributes")
+	if decoder.Err != nil {
+		return nil, errors.Wrap(decoder.Err, "invalid otel for transaction event")
+	}
+	for k, v := range attributes {
+		switch v.(type) {
+		case string, bool, json.Number, float64, int, int64:
+			if otel.Attributes == nil {
+				otel.Attributes = make(map[string]interface{})
+			}
+			otel.Attributes[k] = v
+		default:
+			droppedOTelAttributes.Inc()
+		}
+	}
+	return &otel, nil
+}
+
+// httpMethod returns the http.method attribute, or "" if it is not a string.
+func (otel *OTel) httpMethod() string {
+	if otel == nil {
+		return ""
+	}
+	method, _ := otel.Attributes["http.method"].(string)
+	return method
+}
+
+// labels returns the attributes, with dots in their names replaced by
+// underscores, to be emitted as labels.
+func (otel *OTel) labels() common.MapStr {
+	if otel == nil || len(otel.Attributes) == 0 {
+		return nil
+	}
+	labels := make(common.MapStr, len(otel.Attributes))
+	for k, v := range otel.Attributes {
+		labels[strings.Replace(k, ".", "_", -1)] = v
+	}
+	return labels
+}
+
+// decodeSpanCount decodes the span_count object, leaving counts that are
+// absent unset. Counts must be non-negative integers.
+func decodeSpanCount(raw map[string]interface{}, fieldName func(string) string) (SpanCount, error) {
+	dropped, err := decodeSpanCountValue(raw, "dropped", fieldName)
+	if err != nil {
+		return SpanCount{}, err
+	}
+	started, err := decodeSpanCountValue(raw, "started", fieldName)
+	if err != nil {
+		return SpanCount{}, err
+	}
+	return SpanCount{Dropped: dropped, Started: started}, nil
+}
+
+// decodeSpanCountValue decodes a single span_count value. Integers and
+// integral floats, e.g. 12 and 12.0, decode to the same value; fractional
+// values are rejected rather than truncated.
+func decodeSpanCountValue(raw map[string]interface{}, key string, fieldName func(string) string) (*int, error) {
+	spanCount, _ := raw[fieldName("span_count")].(map[string]interface{})
+	var value int64
+	switch v := spanCount[fieldName(key)].(type) {
+	case nil:
+		return nil, nil
+	case int:
+		value = int64(v)
+	case int64:
+		value = v
+	case float64:
+		i, err := spanCountFromFloat(v, key)
+		if err != nil {
+			return nil, err
+		}
+		value = i
+	case json.Number:
+		// parse integers exactly, float64 loses precision above 2^53
+		if i, err := v.Int64(); err == nil {
+			value = i
+			break
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, errors.Errorf("invalid span_count.%s for transaction event: expected an integer", key)
+		}
+		i, err := spanCountFromFloat(f, key)
+		if err != nil {
+			return nil, err
+		}
+		value = i
+	default:
+		return nil, errors.Errorf("invalid span_count.%s for transaction event: expected an integer", key)
+	}
+	if value < 0 {
+		return nil, errors.Errorf("invalid span_count.%s for transaction event: negative value %v", key, value)
+	}
+	if value > math.MaxInt32 {
+		return nil, errors.Errorf("invalid span_count.%s for transaction event: value %v out of range", key, value)
+	}
+	i := int(value)
+	return &i, nil
+}
+
+// spanCountFromFloat checks that value is a whole number within the span count
+// range before converting it, as converting out of range floats is undefined.
+func spanCountFromFloat(value float64, key string) (int64, error) {
+	if value != math.Trunc(value) || math.IsInf(value, 0) {
+		return 0, errors.Errorf("invalid span_count.%s for transaction event: non-integer value %v", key, value)
+	}
+	if value < 0 {
+		return 0, errors.Errorf("invalid span_count.%s for transaction event: negative value %v", key, value)
+	}
+	if value > math.MaxInt32 {
+		return 0, errors.Errorf("invalid span_count.%s for transaction event: value %v out of range", key, value)
+	}
+	return int64(value), nil
+}
+
+func decodeLinks(input []interface{}, err error) ([]SpanLink, error) {
+	if err != nil || len(input) == 0 {
+		return nil, err
+	}
+	decoder := utility.ManualDecoder{}
+	links := make([]SpanLink, len(input))
+	for i, item := range input {
+		raw, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("invalid type for link at index %d", i)
+		}
+		links[i] = SpanLink{
+			TraceId: decoder.String(raw, "trace_id"),
+			SpanId:  decoder.String(raw, "span_id"),
+		}
+		if decoder.Err != nil {
+			return nil, errors.Wrapf(decoder.Err, "invalid link at index %d", i)
+		}
+		if !isHexID(links[i].TraceId, traceIDLength) {
+			return nil, errors.Errorf("invalid link at index %d: trace_id must be %d hex characters", i, traceIDLength)
+		}
+		if !isHexID(links[i].SpanId, spanIDLength) {
+			return nil, errors.Errorf("invalid link at index %d: span_id must be %d hex characters", i, spanIDLength)
+		}
+	}
+	return links, nil
+}
+
+// maxStackTraceIDs returns the maximum number of profiler stack trace IDs
+// retained per transaction for cfg.
+func maxStackTraceIDs(cfg m.Config) int {
+	if cfg.MaxStackTraceIDs <= 0 {
+		return defaultMaxStackTraceIDs
+	}
+	return cfg.MaxStackTraceIDs
+}
+
+// decodeProfilerStackTraceIDs decodes up to max profiler stack trace IDs,
+// dropping any further IDs. Each ID must be a non-empty string.
+func decodeProfilerStackTraceIDs(input []interface{}, max int, err error) ([]string, error) {
+	if err != nil || len(input) == 0 {
+		return nil, err
+	}
+	if len(input) > max {
+		input = input[:max]
+	}
+	ids := make([]string, len(input))
+	for i, item := range input {
+		id, ok := item.(string)
+		if !ok || id == "" {
+			return nil, errors.Errorf("invalid profiler_stack_trace_ids at index %d: expected a non-empty string", i)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// decodeFAAS decodes faas information from context.faas,
+// falling back to the top-level faas object.
+func decodeFAAS(raw map[string]interface{}) (*FAAS, error) {
+	decoder := utility.ManualDecoder{}
+	input := decoder.MapStr(raw, "faas", "context")
+	if input == nil {
+		input = decoder.MapStr(raw, "faas")
+	}
+	if input == nil && decoder.Err == nil {
+		return nil, nil
+	}
+	faas := FAAS{
+		ID:               decoder.StringPtr(input, "id"),
+		Coldstart:        decoder.BoolPtr(input, "coldstart"),
+		Execution:        decoder.StringPtr(input, "execution"),
+		TriggerType:      decoder.StringPtr(input, "type", "trigger"),
+		TriggerRequestID: decoder.StringPtr(input, "request_id", "trigger"),
+	}
+	if decoder.Err != nil {
+		return nil, errors.Wrap(decoder.Err, "invalid faas")
+	}
+	return &faas, nil
+}
+
+func (f *FAAS) fields() common.MapStr {
+	if f == nil {
+		return nil
+	}
+	fields := common.MapStr{}
+	utility.Set(fields, "id", f.ID)
+	utility.Set(fields, "coldstart", f.Coldstart)
+	utility.Set(fields, "execution", f.Execution)
+	trigger := common.MapStr{}
+	utility.Set(trigger, "type", f.TriggerType)
+	utility.Set(trigger, "request_id", f.TriggerRequestID)
+	utility.Set(fields, "trigger", trigger)
+	return fields
+}
+
+func decodeDroppedSpansStats(input []interface{}, err error) ([]DroppedSpanStats, error) {
+	if err != nil || len(input) == 0 {
+		return nil, err
+	}
+	decoder := utility.ManualDecoder{}
+	stats := make([]DroppedSpanStats, len(input))
+	for i, item := range input {
+		raw, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("invalid type for dropped_spans_stats at index %d", i)
+		}
+		stats[i] = DroppedSpanStats{
+			DestinationServiceResource: decoder.String(raw, "destination_service_resource"),
+			Outcome:                    decoder.String(raw, "outcome"),
+			DurationCount:              decoder.Int(raw, "count", "duration"),
+		}
+		if sum := decoder.Int64Ptr(raw, "us", "duration", "sum"); sum != nil {
+			stats[i].DurationSumUs = *sum
+		} else if decoder.Err == nil {
+			decoder.Err = utility.ErrFetch
+		}
+		if decoder.Err != nil {
+			return nil, errors.Wrapf(decoder.Err, "invalid dropped_spans_stats at index %d", i)
+		}
+		switch stats[i].Outcome {
+		case outcomeSuccess, outcomeFailure, outcomeUnknown:
+		default:
+			return nil, errors.Errorf("invalid dropped_spans_stats at index %d: invalid outcome %q", i, stats[i].Outcome)
+		}
+		if stats[i].DurationCount < 0 {
+			return nil, errors.Errorf("invalid dropped_spans_stats at index %d: negative duration.count %d", i, stats[i].DurationCount)
+		}
+		if stats[i].DurationSumUs < 0 {
+			return nil, errors.Errorf("invalid dropped_spans_stats at index %d: negative duration.sum.us %d", i, stats[i].DurationSumUs)
+		}
+	}
+	return stats, nil
+}
+
+func (s DroppedSpanStats) fields() common.MapStr {
+	return common.MapStr{
+		"destination_service_resource": s.DestinationServiceResource,
+		"outcome":                      s.Outcome,
+		"duration": common.MapStr{
+			"count": s.DurationCount,
+			"sum":   common.MapStr{"us": s.DurationSumUs},
+		},
+	}
+}
+
+// serviceName returns the name of the service the transaction was recorded
+// for, preferring an event-level name over the metadata service name.
+func (e *Event) serviceName() string {
+	for _, service := range []*metadata.Service{e.Service, e.Metadata.Service} {
+		if service != nil && service.Name != nil && *service.Name != "" {
+			return *service.Name
+		}
+	}
+	return ""
+}
+
+// isExcludedService reports whether name matches any of the glob patterns.
+func isExcludedService(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// validateID returns an error if id, held in the given field,
+// does not consist of exactly length lowercase hex characters.
+func validateID(field, id string, length int) error {
+	if len(id) != length {
+		return errors.Errorf("invalid %s for transaction event: expected %d characters, got %d", field, length, len(id))
+	}
+	if !isHexID(id, length) {
+		return errors.Errorf("invalid %s for transaction event: expected lowercase hex characters", field)
+	}
+	return nil
+}
+
+// isHexID reports whether id consists of exactly length lowercase hex characters.
+func isHexID(id string, length int) bool {
+	if len(id) != length {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func (l SpanLink) fields() common.MapStr {
+	return common.MapStr{
+		"trace": common.MapStr{"id": l.TraceId},
+		"span":  common.MapStr{"id": l.SpanId},
+	}
+}
+
+// truncateTracestate drops trailing list members from tracestate until it
+// fits into maxTracestateLength, returning nil if not even the first member fits.
+func truncateTracestate(tracestate string) *string {
+	i := strings.LastIndexByte(tracestate[:maxTracestateLength+1], ',')
+	if i <= 0 {
+		return nil
+	}
+	truncated := tracestate[:i]
+	return &truncated
+}
+
+// limitHeaders returns headers limited to the first max headers by name,
+// in sorted order, so that the headers retained are deterministic.
+func limitHeaders(headers http.Header, max int) http.Header {
+	if len(headers) <= max {
+		return headers
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	limited := make(http.Header, max)
+	for _, name := range names[:max] {
+		limited[name] = headers[name]
+	}
+	return limited
+}
+
+// resultBucket groups a transaction result for use in a transaction category.
+// HTTP status codes and results such as "HTTP 2xx", in any case, are reduced
+// to their class (e.g. "2xx"), other results are lowercased.
+func resultBucket(result string) string {
+	bucket := strings.TrimSpace(strings.TrimPrefix(strings.ToLower(result), "http "))
+	if len(bucket) == 3 && bucket[0] >= '1' && bucket[0] <= '5' {
+		if rest := bucket[1:]; rest == "xx" || (rest[0] >= '0' && rest[0] <= '9' && rest[1] >= '0' && rest[1] <= '9') {
+			return bucket[:1] + "xx"
+		}
+	}
+	return bucket
+}
+
+// coerceBooleanLabels returns a copy of labels, with "true" and "false"
+// string values replaced by booleans. The given labels are left unmodified,
+// as they may be shared with the metadata.
+func coerceBooleanLabels(labels common.MapStr) common.MapStr {
+	coerced := make(common.MapStr, len(labels))
+	for k, v := range labels {
+		switch v {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		}
+		coerced[k] = v
+	}
+	return coerced
+}
+
+// nilIfEmpty returns nil if s points to an empty string, and s otherwise.
+func nilIfEmpty(s *string) *string {
+	if s != nil && *s == "" {
+		return nil
+	}
+	return s
+}
+
+// truncate returns s truncated to at most n characters.
+func truncate(s string, n int) string {
+	var j int
+	for i := range s {
+		if j == n {
+			return s[:i]
+		}
+		j++
+	}
+	return s
+}
+
 func (e *Event) fields(tctx *transform.Context) common.MapStr {
-	tx := common.MapStr{"id": e.Id}
+	tx := tctx.NewMapStr()
+	tx["id"] = e.Id
 	utility.Set(tx, "name", e.Name)
-	utility.Set(tx, "duration", utility.MillisAsMicros(e.Duration))
+	duration := utility.MillisAsMicros(e.Duration)
+	if e.HumanDuration != "" {
+		duration["human"] = e.HumanDuration
+	}
+	if e.DurationSummary != nil {
+		duration["count"] = e.DurationSummary.Count
+		duration["sum"] = common.MapStr{"us": e.DurationSummary.SumUs}
+	}
+	utility.Set(tx, "duration", duration)
 	utility.Set(tx, "type", e.Type)
-	utility.Set(tx, "result", e.Result)
+	if e.TypeTruncated {
+		tx["type_truncated"] = true
+	}
+	if e.HTTPResult != nil {
+		utility.Set(tx, "result", e.HTTPResult)
+	} else {
+		utility.Set(tx, "result", e.Result)
+	}
+	utility.Set(tx, "outcome", e.Outcome)
+	utility.Set(tx, "category", e.Category)
 	utility.Set(tx, "marks", e.Marks)
+	utility.Set(tx, "tracestate", e.Tracestate)
+	if e.TracestateTruncated {
+		tx["tracestate_truncated"] = true
+	}
+	if e.SampleRate != nil {
+		utility.Set(tx, "sample_rate", e.SampleRate)
+		utility.Set(tx, "representative_count", e.RepresentativeCount)
+	}
 	utility.Set(tx, "page", e.Page.Fields())
 	utility.Set(tx, "custom", e.Custom.Fields())
 	utility.Set(tx, "message", e.Message.Fields())
 
-	if e.Sampled == nil {
-		utility.Set(tx, "sampled", true)
+	sampled := e.Sampled == nil || *e.Sampled
+	if e.SampledAsInt {
+		if sampled {
+			tx["sampled"] = 1
+		} else {
+			tx["sampled"] = 0
+		}
 	} else {
-		utility.Set(tx, "sampled", e.Sampled)
+		tx["sampled"] = sampled
 	}
 
 	if e.SpanCount.Dropped != nil || e.SpanCount.Started != nil {
@@ -227,36 +1164,257 @@ func (e *Event) fields(tctx *transform.Context) common.MapStr {
 		utility.Set(tx, "span_count", spanCount)
 	}
 
+	if len(e.ProfilerStackTraceIDs) > 0 {
+		tx["profiler_stack_trace_ids"] = e.ProfilerStackTraceIDs
+	}
+
+	if len(e.DroppedSpansStats) > 0 {
+		stats := make([]common.MapStr, len(e.DroppedSpansStats))
+		for i, s := range e.DroppedSpansStats {
+			stats[i] = s.fields()
+		}
+		tx["dropped_spans_stats"] = stats
+	}
+
 	return tx
 }
 
 func (e *Event) Transform(ctx context.Context, tctx *transform.Context) []beat.Event {
+	now := tctx.IngestTime()
+	if e.dropFutureTimestamp(now) {
+		return nil
+	}
+	return []beat.Event{e.transform(tctx, now)}
+}
+
+// TransformAll transforms events into a single slice of beat.Events, sized
+// up front to avoid growing it for large batches.
+func TransformAll(ctx context.Context, tctx *transform.Context, events []*Event) []beat.Event {
+	now := tctx.IngestTime()
+	out := make([]beat.Event, 0, len(events))
+	for _, e := range events {
+		if e.dropFutureTimestamp(now) {
+			continue
+		}
+		out = append(out, e.transform(tctx, now))
+	}
+	return out
+}
+
+// futureTimestamp reports whether e.Timestamp is more than
+// e.MaxFutureSkew ahead of now.
+func (e *Event) futureTimestamp(now time.Time) bool {
+	return e.MaxFutureSkew > 0 && e.Timestamp.After(now.Add(e.MaxFutureSkew))
+}
+
+// dropFutureTimestamp reports whether e is to be dropped for having
+// a timestamp too far ahead of now, counting it if so.
+func (e *Event) dropFutureTimestamp(now time.Time) bool {
+	if e.ClampFutureTimestamps || !e.futureTimestamp(now) {
+		return false
+	}
+	droppedFutureTimestamps.Inc()
+	return true
+}
+
+// transform transforms e at time now, the time reported by tctx.IngestTime.
+func (e *Event) transform(tctx *transform.Context, now time.Time) beat.Event {
 	transformations.Inc()
 
-	fields := common.MapStr{
-		"processor":        processorEntry,
-		transactionDocType: e.fields(tctx),
+	timestamp := e.Timestamp
+	if e.futureTimestamp(now) {
+		// only reached with ClampFutureTimestamps set
+		timestamp = now
 	}
 
+	fields := tctx.NewMapStr()
+	fields["processor"] = processorEntry
+	if e.MarkZeroDurationAsSpan && e.Duration == 0 {
+		fields["processor"] = markProcessorEntry
+	}
+	fields[transactionDocType] = e.fields(tctx)
+
 	// first set generic metadata (order is relevant)
 	e.Metadata.Set(fields)
 
 	// then merge event specific information
 	utility.Update(fields, "user", e.User.Fields())
-	clientFields := e.Client.Fields()
+	clientFields := e.Client.ResolveGeo(e.GeoResolver, tctx.GeoLookup).Fields()
 	utility.DeepUpdate(fields, "client", clientFields)
-	utility.DeepUpdate(fields, "source", clientFields)
+	utility.DeepUpdate(fields, "source", e.Client.SourceFields())
 	utility.DeepUpdate(fields, "user_agent", e.User.UserAgentFields())
+	// event service fields take precedence over the metadata service
+	// field by field, except for the framework name and version, which
+	// are overridden together so that a version is never attributed to
+	// another framework
+	if e.Service != nil && e.Service.Framework.Name != nil {
+		fields.Delete("service.framework")
+	}
 	utility.DeepUpdate(fields, "service", e.Service.Fields(emptyString, emptyString))
 	utility.DeepUpdate(fields, "agent", e.Service.AgentFields())
+	// merges with metadata cloud, overrides conflicting keys
+	utility.DeepUpdate(fields, "cloud", e.Cloud.Fields())
 	utility.AddId(fields, "parent", e.ParentId)
 	utility.AddId(fields, "trace", &e.TraceId)
-	utility.Set(fields, "timestamp", utility.TimeAsMicros(e.Timestamp))
+	utility.Set(fields, "timestamp", utility.TimeAsMicros(timestamp))
 	// merges with metadata labels, overrides conflicting keys
+	utility.DeepUpdate(fields, "labels", e.OTel.labels())
 	utility.DeepUpdate(fields, "labels", e.Labels.Fields())
+	if labels, ok := fields["labels"].(common.MapStr); ok && e.CoerceBooleanLabels {
+		fields["labels"] = coerceBooleanLabels(labels)
+	}
+	if labels, ok := fields["labels"].(common.MapStr); ok && e.StringifyLabels {
+		fields["labels"] = m.StringLabels(labels)
+	}
 	utility.Set(fields, "http", e.Http.Fields())
 	utility.Set(fields, "url", e.Url.Fields())
+	if ext := e.Url.Extension(); ext != nil {
+		utility.DeepUpdate(fields, "url.extension", *ext)
+	}
+	if e.Url == nil || e.Url.Domain == nil {
+		// RUM agents only send the page URL, derive the domain from it
+		if domain := e.Page.Domain(); domain != nil {
+			utility.DeepUpdate(fields, "url.domain", *domain)
+		}
+	}
+	if e.EmitRefererDomain {
+		if domain := e.Page.RefererDomain(); domain != nil {
+			utility.DeepUpdate(fields, "http.request.referrer.domain", *domain)
+		}
+	}
 	utility.Set(fields, "experimental", e.Experimental)
+	utility.Set(fields, "faas", e.FAAS.fields())
+	if len(e.Links) > 0 {
+		links := make([]common.MapStr, len(e.Links))
+		for i, link := range e.Links {
+			links[i] = link.fields()
+		}
+		utility.DeepUpdate(fields, "span.links", links)
+	}
+	if e.OTel != nil && e.OTel.SpanKind != "" {
+		utility.DeepUpdate(fields, "span.kind", e.OTel.SpanKind)
+	}
 
-	return []beat.Event{{Fields: fields, Timestamp: e.Timestamp}}
+	event := common.MapStr{}
+	ingested := now
+	if e.EmitEventCreated {
+		created := ingested
+		if e.EventCreated != nil {
+			created = *e.EventCreated
+		}
+		event["created"] = created.UTC().Format(time.RFC3339Nano)
+	}
+	event["ingested"] = ingested.UTC().Format(time.RFC3339Nano)
+	if len(e.EventCategory) > 0 {
+		event["category"] = e.EventCategory
+	}
+	if e.EventDuration != nil {
+		event["duration"] = *e.EventDuration
+	}
+	if e.EventDropped != nil {
+		event["dropped"] = *e.EventDropped
+	}
+	if e.EventReference != nil {
+		event["reference"] = *e.EventReference
+	}
+	if e.Message != nil && e.Message.AgeMillis != nil {
+		// the message age in seconds, for queue lag dashboards
+		event["age"] = float64(*e.Message.AgeMillis) / 1000
+	}
+	utility.DeepUpdate(fields, "event", event)
+
+	return beat.Event{
+		Fields:    fields,
+		Timestamp: timestamp,
+	}
+}
+
+// OutcomeMetrics returns a metricset counting the transaction as a success
+// or failure, for deriving success and failure rates. Transactions without
+// a success or failure outcome produce no metricsets.
+func (e *Event) OutcomeMetrics() []*metricset.Metricset {
+	if e.Outcome == nil {
+		return nil
+	}
+	var success, failure float64
+	switch *e.Outcome {
+	case outcomeSuccess:
+		success = 1
+	case outcomeFailure:
+		failure = 1
+	default:
+		return nil
+	}
+	transactionType := e.Type
+	counter := "counter"
+	return []*metricset.Metricset{{
+		Metadata:  e.Metadata,
+		Timestamp: e.Timestamp,
+		Transaction: &metricset.Transaction{
+			Name: e.Name,
+			Type: &transactionType,
+		},
+		Samples: []*metricset.Sample{
+			{Name: "event.success.count", Value: success, Type: &counter},
+			{Name: "event.failure.count", Value: failure, Type: &counter},
+		},
+	}}
+}
+
+// AggregateDurations bins the durations of events into buckets with the given
+// ascending upper bounds, returning the weighted count of each bucket along
+// with its upper bound. Events are weighted by their representative count if
+// they were sampled with a sample rate, and count once otherwise. Events with
+// zero duration fall into the first bucket. A final overflow bucket holds the
+// events exceeding the top bound, its value is the largest such duration.
+func AggregateDurations(events []*Event, bounds []float64) (counts []int64, values []float64) {
+	weights := make([]float64, len(bounds)+1)
+	values = make([]float64, len(bounds)+1)
+	copy(values, bounds)
+	for _, e := range events {
+		weight := 1.0
+		if e.SampleRate != nil {
+			weight = e.RepresentativeCount
+		}
+		i := 0
+		if e.Duration > 0 {
+			i = sort.SearchFloat64s(bounds, e.Duration)
+		}
+		if i == len(bounds) && e.Duration > values[i] {
+			values[i] = e.Duration
+		}
+		weights[i] += weight
+	}
+	counts = make([]int64, len(weights))
+	for i, w := range weights {
+		counts[i] = int64(math.Round(w))
+	}
+	return counts, values
+}
+
+// EstimatedSize returns a cheap estimate of the serialized size of the
+// transaction in bytes, including its context and metadata, without
+// marshaling it. See utility.EstimatedSize.
+func (e *Event) EstimatedSize() int {
+	size := utility.EstimatedSize(
+		e.Id, e.TraceId, e.Type, e.ParentId, e.Name, e.Result, e.Outcome, e.Category, e.Tracestate,
+		e.SampleRate, e.SpanCount.Dropped, e.SpanCount.Started,
+		e.Marks, e.Experimental,
+	)
+	// timestamp and duration are always set
+	size += 2 * utility.NumericFieldSize
+	for _, link := range e.Links {
+		size += len(link.TraceId) + len(link.SpanId)
+	}
+	size += e.Http.EstimatedSize() +
+		e.Url.EstimatedSize() +
+		e.Page.EstimatedSize() +
+		e.Labels.EstimatedSize() +
+		e.Custom.EstimatedSize() +
+		e.Message.EstimatedSize() +
+		e.Client.EstimatedSize() +
+		e.User.EstimatedSize() +
+		e.Service.EstimatedSize() +
+		e.Metadata.EstimatedSize()
+	return size
 }
@@ -18,22 +18,30 @@
 package transaction
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/common"
 
+	"github.com/elastic/apm-server/decoder"
 	"github.com/elastic/apm-server/model"
 	"github.com/elastic/apm-server/model/metadata"
+	"github.com/elastic/apm-server/model/metricset"
 	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/apm-server/tests/loader"
 	"github.com/elastic/apm-server/transform"
 	"github.com/elastic/apm-server/utility"
 )
@@ -61,6 +69,1324 @@ func TestTransactionEventDecodeFailure(t *testing.T) {
 	}
 }
 
+func TestTransactionEventDecodeOutcome(t *testing.T) {
+	statusCode := func(code int) map[string]interface{} {
+		return map[string]interface{}{
+			"response": map[string]interface{}{"status_code": json.Number(fmt.Sprint(code))},
+		}
+	}
+	for name, test := range map[string]struct {
+		outcome  interface{}
+		context  map[string]interface{}
+		expected *string
+		err      error
+	}{
+		"success":              {outcome: "success", expected: tests.StringPtr("success")},
+		"failure":              {outcome: "failure", expected: tests.StringPtr("failure")},
+		"unknown":              {outcome: "unknown", expected: tests.StringPtr("unknown")},
+		"invalid":              {outcome: "broken", err: errInvalidOutcome},
+		"absent":               {},
+		"derived from 2xx":     {context: statusCode(200), expected: tests.StringPtr("success")},
+		"derived from 4xx":     {context: statusCode(404), expected: tests.StringPtr("success")},
+		"derived from 5xx":     {context: statusCode(503), expected: tests.StringPtr("failure")},
+		"explicit wins on 5xx": {outcome: "success", context: statusCode(500), expected: tests.StringPtr("success")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.outcome != nil {
+				input["outcome"] = test.outcome
+			}
+			if test.context != nil {
+				input["context"] = test.context
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input})
+			if test.err != nil {
+				assert.Equal(t, test.err, err)
+				assert.Nil(t, transformable)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, transformable.(*Event).Outcome)
+		})
+	}
+}
+
+func TestTransactionEventDecodeValidateServiceVersion(t *testing.T) {
+	for name, test := range map[string]struct {
+		version  string
+		validate bool
+		err      string
+	}{
+		"semver":                   {version: "1.2.3", validate: true},
+		"semver with prerelease":   {version: "1.0.0-alpha.1", validate: true},
+		"semver with build":        {version: "1.0.0+20200325.abc", validate: true},
+		"missing patch":            {version: "1.2", validate: true, err: `invalid service version "1.2"`},
+		"leading v":                {version: "v1.2.3", validate: true, err: `invalid service version "v1.2.3"`},
+		"leading zero":             {version: "01.2.3", validate: true, err: `invalid service version "01.2.3"`},
+		"free text":                {version: "latest", validate: true, err: `invalid service version "latest"`},
+		"free text, no validation": {version: "latest"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+					"context": map[string]interface{}{"service": map[string]interface{}{"version": test.version}},
+				},
+				Config: model.Config{ValidateServiceVersion: test.validate},
+			})
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				assert.Nil(t, transformable)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.version, *transformable.(*Event).Service.Version)
+		})
+	}
+}
+
+func TestTransactionEventDecodeValidateUserEmail(t *testing.T) {
+	for name, test := range map[string]struct {
+		email    string
+		validate bool
+		expected *string
+	}{
+		"valid":                  {email: "user@example.com", validate: true, expected: tests.StringPtr("user@example.com")},
+		"subdomain":              {email: "first.last@mail.example.co.uk", validate: true, expected: tests.StringPtr("first.last@mail.example.co.uk")},
+		"missing at":             {email: "user.example.com", validate: true},
+		"missing domain":         {email: "user@", validate: true},
+		"missing tld":            {email: "user@localhost", validate: true},
+		"whitespace":             {email: "user name@example.com", validate: true},
+		"invalid, no validation": {email: "not an email", expected: tests.StringPtr("not an email")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+					"context": map[string]interface{}{"user": map[string]interface{}{"id": "1", "email": test.email}},
+				},
+				Config: model.Config{ValidateUserEmail: test.validate},
+			})
+			require.NoError(t, err)
+			user := transformable.(*Event).User
+			require.NotNil(t, user)
+			assert.Equal(t, test.expected, user.Email)
+			assert.Equal(t, "1", *user.Id)
+		})
+	}
+}
+
+func TestTransactionEventDecodeOmitZeroSpanCount(t *testing.T) {
+	for name, test := range map[string]struct {
+		spanCount map[string]interface{}
+		omit      bool
+		expected  interface{}
+	}{
+		"zero":                 {spanCount: map[string]interface{}{"started": 0.0, "dropped": 0.0}, omit: true},
+		"absent":               {spanCount: map[string]interface{}{}, omit: true},
+		"partial":              {spanCount: map[string]interface{}{"started": 3.0, "dropped": 0.0}, omit: true, expected: common.MapStr{"started": 3}},
+		"full":                 {spanCount: map[string]interface{}{"started": 3.0, "dropped": 2.0}, omit: true, expected: common.MapStr{"started": 3, "dropped": 2}},
+		"zero, no omission":    {spanCount: map[string]interface{}{"started": 0.0, "dropped": 0.0}, expected: common.MapStr{"started": 0, "dropped": 0}},
+		"partial, no omission": {spanCount: map[string]interface{}{"started": 3.0, "dropped": 0.0}, expected: common.MapStr{"started": 3, "dropped": 0}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+					"span_count": test.spanCount,
+				},
+				Config: model.Config{OmitZeroSpanCount: test.omit},
+			})
+			require.NoError(t, err)
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			tx := output[0].Fields["transaction"].(common.MapStr)
+			if test.expected == nil {
+				assert.NotContains(t, tx, "span_count")
+			} else {
+				assert.Equal(t, test.expected, tx["span_count"])
+			}
+		})
+	}
+}
+
+func TestTransactionEventDecodeSpanCount(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+	for name, test := range map[string]struct {
+		spanCount interface{}
+		expected  SpanCount
+		err       string
+	}{
+		"absent":           {},
+		"started only":     {spanCount: map[string]interface{}{"started": 4.0}, expected: SpanCount{Started: intPtr(4)}},
+		"dropped only":     {spanCount: map[string]interface{}{"dropped": 2.0}, expected: SpanCount{Dropped: intPtr(2)}},
+		"both":             {spanCount: map[string]interface{}{"started": 4.0, "dropped": 0.0}, expected: SpanCount{Started: intPtr(4), Dropped: intPtr(0)}},
+		"json number":      {spanCount: map[string]interface{}{"started": json.Number("7")}, expected: SpanCount{Started: intPtr(7)}},
+		"integer":          {spanCount: map[string]interface{}{"started": 12, "dropped": int64(3)}, expected: SpanCount{Started: intPtr(12), Dropped: intPtr(3)}},
+		"integral float":   {spanCount: map[string]interface{}{"started": 12.0, "dropped": 3.0}, expected: SpanCount{Started: intPtr(12), Dropped: intPtr(3)}},
+		"integral json":    {spanCount: map[string]interface{}{"started": json.Number("12.0"), "dropped": json.Number("3")}, expected: SpanCount{Started: intPtr(12), Dropped: intPtr(3)}},
+		"fractional float": {spanCount: map[string]interface{}{"started": 12.5}, err: "invalid span_count.started for transaction event: non-integer value 12.5"},
+		"negative dropped": {spanCount: map[string]interface{}{"started": 4.0, "dropped": -1.0}, err: "invalid span_count.dropped"},
+		"negative started": {spanCount: map[string]interface{}{"started": -3.0}, err: "invalid span_count.started"},
+		"fractional":       {spanCount: map[string]interface{}{"started": 4.0, "dropped": 1.5}, err: "invalid span_count.dropped"},
+		"fractional json":  {spanCount: map[string]interface{}{"started": json.Number("1.5")}, err: "invalid span_count.started"},
+		"string":           {spanCount: map[string]interface{}{"started": "4"}, err: "invalid span_count.started"},
+		"float too large":  {spanCount: map[string]interface{}{"started": 1e20}, err: "invalid span_count.started for transaction event: value 1e+20 out of range"},
+		"json too large":   {spanCount: map[string]interface{}{"started": json.Number("1e20")}, err: "invalid span_count.started for transaction event: value 1e+20 out of range"},
+		"int64 too large":  {spanCount: map[string]interface{}{"dropped": int64(math.MaxInt32) + 1}, err: "invalid span_count.dropped for transaction event: value 2147483648 out of range"},
+		"json beyond 2^53": {spanCount: map[string]interface{}{"started": json.Number("9007199254740993")}, err: "invalid span_count.started for transaction event: value 9007199254740993 out of range"},
+		"json max":         {spanCount: map[string]interface{}{"started": json.Number("2147483647")}, expected: SpanCount{Started: intPtr(math.MaxInt32)}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.spanCount != nil {
+				input["span_count"] = test.spanCount
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input})
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, transformable.(*Event).SpanCount)
+		})
+	}
+}
+
+func TestTransactionEventDecodeDefaultSpanCount(t *testing.T) {
+	for name, test := range map[string]struct {
+		sampled   interface{}
+		spanCount interface{}
+		omitZero  bool
+		expected  interface{}
+	}{
+		"sampled without span_count":            {expected: common.MapStr{"started": 0}},
+		"explicitly sampled without span_count": {sampled: true, expected: common.MapStr{"started": 0}},
+		"unsampled without span_count":          {sampled: false},
+		"explicit span_count": {
+			spanCount: map[string]interface{}{"started": 4.0, "dropped": 1.0},
+			expected:  common.MapStr{"started": 4, "dropped": 1},
+		},
+		"dropped only": {
+			spanCount: map[string]interface{}{"dropped": 1.0},
+			expected:  common.MapStr{"started": 0, "dropped": 1},
+		},
+		"omitting zero counts without span_count": {omitZero: true, expected: common.MapStr{"started": 0}},
+		"omitting zero counts": {
+			spanCount: map[string]interface{}{"started": 0.0, "dropped": 0.0},
+			omitZero:  true,
+			expected:  common.MapStr{"started": 0},
+		},
+		"unsampled omitting zero counts": {
+			sampled:   false,
+			spanCount: map[string]interface{}{"started": 0.0},
+			omitZero:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.sampled != nil {
+				input["sampled"] = test.sampled
+			}
+			if test.spanCount != nil {
+				input["span_count"] = test.spanCount
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input, Config: model.Config{DefaultSpanCount: true, OmitZeroSpanCount: test.omitZero}})
+			require.NoError(t, err)
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			spanCount, _ := output[0].Fields.GetValue("transaction.span_count")
+			assert.Equal(t, test.expected, spanCount)
+		})
+	}
+}
+
+func TestTransactionEventDroppedCopiesSpanCount(t *testing.T) {
+	input := map[string]interface{}{
+		"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+		"span_count": map[string]interface{}{"started": 4.0, "dropped": 2.0},
+	}
+	transformable, err := DecodeEvent(model.Input{Raw: input, Config: model.Config{EmitEventDropped: true}})
+	require.NoError(t, err)
+	event := transformable.(*Event)
+	require.NotNil(t, event.EventDropped)
+	assert.False(t, event.EventDropped == event.SpanCount.Dropped, "event.dropped must not alias span_count.dropped")
+
+	*event.SpanCount.Dropped = 0
+	assert.Equal(t, 2, *event.EventDropped)
+}
+
+func TestTransactionEventMessageAge(t *testing.T) {
+	for name, test := range map[string]struct {
+		age      interface{}
+		ageMs    interface{}
+		eventAge interface{}
+		err      string
+	}{
+		"zero":     {age: json.Number("0"), ageMs: 0, eventAge: int64(0)},
+		"positive": {age: json.Number("1500"), ageMs: 1500, eventAge: common.Float(1.5)},
+		"negative": {age: json.Number("-1"), err: "invalid message age -1"},
+		"absent":   {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			message := map[string]interface{}{"queue": map[string]interface{}{"name": "orders"}}
+			if test.age != nil {
+				message["age"] = map[string]interface{}{"ms": test.age}
+			}
+			input := map[string]interface{}{
+				"id": "123", "type": "messaging", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+				"context": map[string]interface{}{"message": message},
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input})
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			require.NoError(t, err)
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			ageMs, _ := output[0].Fields.GetValue("transaction.message.age.ms")
+			assert.Equal(t, test.ageMs, ageMs)
+			eventAge, _ := output[0].Fields.GetValue("event.age")
+			assert.Equal(t, test.eventAge, eventAge)
+		})
+	}
+}
+
+func TestTransactionEventMaxRequestHeaders(t *testing.T) {
+	headers := map[string]interface{}{
+		"User-Agent":    "curl/7.64.1",
+		"Accept":        "*/*",
+		"Cookie":        []interface{}{"a=1", "b=2"},
+		"Cache-Control": "no-cache",
+	}
+	for name, test := range map[string]struct {
+		max      int
+		expected http.Header
+	}{
+		"under": {max: 5, expected: http.Header{
+			"Accept": {"*/*"}, "Cache-Control": {"no-cache"}, "Cookie": {"a=1", "b=2"}, "User-Agent": {"curl/7.64.1"},
+		}},
+		"at": {max: 4, expected: http.Header{
+			"Accept": {"*/*"}, "Cache-Control": {"no-cache"}, "Cookie": {"a=1", "b=2"}, "User-Agent": {"curl/7.64.1"},
+		}},
+		"over": {max: 2, expected: http.Header{"Accept": {"*/*"}, "Cache-Control": {"no-cache"}}},
+		"unlimited": {expected: http.Header{
+			"Accept": {"*/*"}, "Cache-Control": {"no-cache"}, "Cookie": {"a=1", "b=2"}, "User-Agent": {"curl/7.64.1"},
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{
+				"id": "123", "type": "request", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+				"context": map[string]interface{}{"request": map[string]interface{}{"method": "GET", "headers": headers}},
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input, Config: model.Config{MaxRequestHeaders: test.max}})
+			require.NoError(t, err)
+			event := transformable.(*Event)
+			require.NotNil(t, event.Http)
+			assert.Equal(t, test.expected, event.Http.Request.Headers)
+		})
+	}
+}
+
+func TestTransactionEventDecodeMaxRequestBodyBytes(t *testing.T) {
+	long := strings.Repeat("a", 4096)
+	for name, test := range map[string]struct {
+		max       int
+		expected  string
+		truncated bool
+	}{
+		"truncated": {max: 4, expected: "aaaa", truncated: true},
+		"no limit":  {expected: long},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+					"context": map[string]interface{}{
+						"request": map[string]interface{}{"method": "POST", "body": long},
+					},
+				},
+				Config: model.Config{MaxRequestBodyBytes: test.max},
+			})
+			require.NoError(t, err)
+			request := transformable.(*Event).Http.Request
+			assert.Equal(t, test.expected, request.Body)
+			assert.Equal(t, test.truncated, request.BodyTruncated)
+		})
+	}
+}
+
+func TestTransactionEventDecodeSanitizeCustomKeys(t *testing.T) {
+	for name, test := range map[string]struct {
+		sanitize bool
+		expected *model.Custom
+	}{
+		"sanitized":     {sanitize: true, expected: &model.Custom{"a_b": "c", "nested": map[string]interface{}{"d_e": true}}},
+		"not sanitized": {expected: &model.Custom{"a.b": "c", "nested": map[string]interface{}{"d.e": true}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+					"context": map[string]interface{}{"custom": map[string]interface{}{
+						"a.b": "c", "nested": map[string]interface{}{"d.e": true},
+					}},
+				},
+				Config: model.Config{SanitizeCustomKeys: test.sanitize},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, transformable.(*Event).Custom)
+		})
+	}
+}
+
+func TestTransactionEventDecodeSanitizeCustomKeysCollision(t *testing.T) {
+	raw := map[string]interface{}{
+		"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+		"context": map[string]interface{}{"custom": map[string]interface{}{"a_b": "plain", "a.b": "dotted"}},
+	}
+	transformable, err := DecodeEvent(model.Input{Raw: raw, Config: model.Config{SanitizeCustomKeys: true}})
+	require.NoError(t, err)
+	assert.Equal(t, &model.Custom{"a_b": "plain", "a_b_2": "dotted"}, transformable.(*Event).Custom)
+
+	output := transformable.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	custom, _ := output[0].Fields.GetValue("transaction.custom")
+	assert.Equal(t, common.MapStr{"a_b": "plain", "a_b_2": "dotted"}, custom)
+}
+
+func TestTransactionEventMarkZeroDurationAsSpan(t *testing.T) {
+	for name, test := range map[string]struct {
+		duration float64
+		mark     bool
+		expected string
+	}{
+		"zero duration":            {duration: 0, mark: true, expected: "span"},
+		"nonzero duration":         {duration: 1.5, mark: true, expected: "transaction"},
+		"zero duration not marked": {duration: 0, expected: "transaction"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "mark", "duration": test.duration, "trace_id": "0123456789abcdef0123456789abcdef"}
+			transformable, err := DecodeEvent(model.Input{Raw: input, Config: model.Config{MarkZeroDurationAsSpan: test.mark}})
+			require.NoError(t, err)
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			assert.Equal(t, common.MapStr{"name": "transaction", "event": test.expected}, output[0].Fields["processor"])
+		})
+	}
+}
+
+func TestTransactionEventDecodeRelativeTimestamp(t *testing.T) {
+	requestTime := time.Date(2019, 1, 3, 15, 17, 4, 0, time.UTC)
+	for name, test := range map[string]struct {
+		timestamp interface{}
+		expected  time.Time
+		err       string
+	}{
+		"absolute":        {timestamp: json.Number("1496170422281000"), expected: time.Date(2017, 5, 30, 18, 53, 42, 281000000, time.UTC)},
+		"absent":          {expected: requestTime},
+		"positive offset": {timestamp: "+1500", expected: requestTime.Add(1500 * time.Microsecond)},
+		"negative offset": {timestamp: "-2000000", expected: requestTime.Add(-2 * time.Second)},
+		"missing sign":    {timestamp: "1500", err: "invalid relative timestamp \"1500\": expected a leading sign"},
+		"malformed sign":  {timestamp: "+-1500", err: "invalid relative timestamp \"+-1500\": expected a number of microseconds"},
+		"sign only":       {timestamp: "-", err: "invalid relative timestamp \"-\""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.timestamp != nil {
+				raw["timestamp"] = test.timestamp
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: raw, RequestTime: requestTime})
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, transformable.(*Event).Timestamp)
+		})
+	}
+}
+
+func TestEventTransformMaxFutureSkew(t *testing.T) {
+	now := time.Date(2019, 1, 3, 15, 17, 4, 0, time.UTC)
+	tctx := &transform.Context{Now: func() time.Time { return now }}
+	for name, test := range map[string]struct {
+		offset   time.Duration
+		clamp    bool
+		dropped  bool
+		expected time.Time
+	}{
+		"now":                 {expected: now},
+		"past":                {offset: -time.Hour, expected: now.Add(-time.Hour)},
+		"near future":         {offset: time.Minute, expected: now.Add(time.Minute)},
+		"far future":          {offset: time.Hour, dropped: true},
+		"far future, clamped": {offset: time.Hour, clamp: true, expected: now},
+	} {
+		t.Run(name, func(t *testing.T) {
+			timestamp := now.Add(test.offset).UnixNano() / 1000
+			input := map[string]interface{}{
+				"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+				"timestamp": json.Number(fmt.Sprint(timestamp)),
+			}
+			transformable, err := DecodeEvent(model.Input{
+				Raw: input,
+				// the request time is not used for the check
+				RequestTime: now.Add(24 * time.Hour),
+				Config:      model.Config{MaxFutureSkew: 5 * time.Minute, ClampFutureTimestamps: test.clamp},
+			})
+			require.NoError(t, err)
+			event := transformable.(*Event)
+			assert.Equal(t, now.Add(test.offset), event.Timestamp, "decoded timestamp is kept as sent")
+
+			output := event.Transform(context.Background(), tctx)
+			all := TransformAll(context.Background(), tctx, []*Event{event, event})
+			if test.dropped {
+				assert.Empty(t, output)
+				assert.Empty(t, all)
+				return
+			}
+			require.Len(t, output, 1)
+			assert.Equal(t, test.expected, output[0].Timestamp)
+			assert.Equal(t, common.MapStr{"us": test.expected.UnixNano() / 1000}, output[0].Fields["timestamp"])
+			assert.Len(t, all, 2)
+		})
+	}
+}
+
+func TestTransactionEventDecodeEventDropped(t *testing.T) {
+	for name, test := range map[string]struct {
+		spanCount interface{}
+		emit      bool
+		omitZero  bool
+		expected  interface{}
+	}{
+		"dropped":                     {spanCount: map[string]interface{}{"started": 4.0, "dropped": 2.0}, emit: true, expected: 2},
+		"zero dropped":                {spanCount: map[string]interface{}{"started": 4.0, "dropped": 0.0}, emit: true, expected: 0},
+		"zero dropped, omitting zero": {spanCount: map[string]interface{}{"started": 4.0, "dropped": 0.0}, emit: true, omitZero: true},
+		"dropped absent":              {spanCount: map[string]interface{}{"started": 4.0}, emit: true},
+		"span_count absent":           {emit: true},
+		"disabled":                    {spanCount: map[string]interface{}{"started": 4.0, "dropped": 2.0}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.spanCount != nil {
+				input["span_count"] = test.spanCount
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input, Config: model.Config{EmitEventDropped: test.emit, OmitZeroSpanCount: test.omitZero}})
+			require.NoError(t, err)
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			dropped, _ := output[0].Fields.GetValue("event.dropped")
+			assert.Equal(t, test.expected, dropped)
+		})
+	}
+}
+
+func TestTransactionEventDecodeEventReference(t *testing.T) {
+	for name, test := range map[string]struct {
+		parentID interface{}
+		emit     bool
+		expected interface{}
+	}{
+		"child":    {parentID: "abcdef0123456789", emit: true, expected: "abcdef0123456789"},
+		"root":     {emit: true},
+		"disabled": {parentID: "abcdef0123456789"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.parentID != nil {
+				input["parent_id"] = test.parentID
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input, Config: model.Config{EmitEventReference: test.emit}})
+			require.NoError(t, err)
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			reference, _ := output[0].Fields.GetValue("event.reference")
+			assert.Equal(t, test.expected, reference)
+		})
+	}
+}
+
+func TestTransactionEventDecodeCollectAllErrors(t *testing.T) {
+	input := map[string]interface{}{
+		"id": "123", "type": 1.0, "duration": "slow", "sampled": "yes",
+		"trace_id": "0123456789abcdef0123456789abcdef",
+	}
+
+	_, err := DecodeEvent(model.Input{Raw: input})
+	assert.Equal(t, utility.ErrFetch, err)
+
+	_, err = DecodeEvent(model.Input{Raw: input, Config: model.Config{CollectAllErrors: true}})
+	require.IsType(t, utility.Errors{}, err)
+	assert.Equal(t, []error{
+		&utility.FieldError{Field: "type", Err: utility.ErrFetch},
+		&utility.FieldError{Field: "duration", Err: utility.ErrFetch},
+		&utility.FieldError{Field: "sampled", Err: utility.ErrFetch},
+	}, err.(utility.Errors).Unwrap())
+	assert.EqualError(t, err, "type: error fetching field; duration: error fetching field; sampled: error fetching field")
+
+	input["timestamp"] = "+abc"
+	input["context"] = map[string]interface{}{"request": map[string]interface{}{"method": 1.0}}
+	_, err = DecodeEvent(model.Input{Raw: input, Config: model.Config{CollectAllErrors: true}})
+	require.IsType(t, utility.Errors{}, err)
+	assert.Len(t, err.(utility.Errors).Unwrap(), 5)
+}
+
+func TestTransactionEventDecodeCollapseNameWhitespace(t *testing.T) {
+	for name, test := range map[string]struct {
+		name     string
+		collapse bool
+		expected string
+	}{
+		"clean":       {name: "GET /users/:id", collapse: true, expected: "GET /users/:id"},
+		"multi-space": {name: "GET  /users/ :id", collapse: true, expected: "GET /users/ :id"},
+		"mixed":       {name: " GET\t\t/users\n ", collapse: true, expected: " GET /users "},
+		"disabled":    {name: "GET  /users", expected: "GET  /users"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "name": test.name, "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			transformable, err := DecodeEvent(model.Input{Raw: input, Config: model.Config{CollapseNameWhitespace: test.collapse}})
+			require.NoError(t, err)
+			event := transformable.(*Event)
+			require.NotNil(t, event.Name)
+			assert.Equal(t, test.expected, *event.Name)
+		})
+	}
+}
+
+func TestTransactionEventOTel(t *testing.T) {
+	raw := map[string]interface{}{
+		"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+		"context": map[string]interface{}{"tags": map[string]interface{}{"http_method": "POST"}},
+		"otel": map[string]interface{}{
+			"span_kind": "SERVER",
+			"attributes": map[string]interface{}{
+				"http.method":      "GET",
+				"http.status_code": json.Number("200"),
+				"net.peer.port":    8080.0,
+				"retry":            true,
+				"nested":           map[string]interface{}{"a": "b"},
+				"list":             []interface{}{"a"},
+			},
+		},
+	}
+	before := droppedOTelAttributes.Get()
+	transformable, err := DecodeEvent(model.Input{Raw: raw})
+	require.NoError(t, err)
+	assert.Equal(t, before+2, droppedOTelAttributes.Get())
+
+	event := transformable.(*Event)
+	assert.Equal(t, &OTel{
+		SpanKind: "SERVER",
+		Attributes: map[string]interface{}{
+			"http.method": "GET", "http.status_code": json.Number("200"), "net.peer.port": 8080.0, "retry": true,
+		},
+	}, event.OTel)
+
+	output := transformable.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	spanKind, err := output[0].Fields.GetValue("span.kind")
+	require.NoError(t, err)
+	assert.Equal(t, "SERVER", spanKind)
+	// event labels override attributes with conflicting names
+	assert.Equal(t, common.MapStr{
+		"http_method": "POST", "http_status_code": int64(200), "net_peer_port": int64(8080), "retry": true,
+	}, output[0].Fields["labels"])
+}
+
+func TestTransactionEventOTelHTTPMethod(t *testing.T) {
+	otel := func(method interface{}) map[string]interface{} {
+		return map[string]interface{}{"span_kind": "SERVER", "attributes": map[string]interface{}{"http.method": method}}
+	}
+	request := func(method string) map[string]interface{} {
+		return map[string]interface{}{"request": map[string]interface{}{"method": method, "url": map[string]interface{}{"raw": "/"}}}
+	}
+	for name, test := range map[string]struct {
+		otel     map[string]interface{}
+		context  map[string]interface{}
+		expected interface{}
+	}{
+		"attribute only":                  {otel: otel("GET"), expected: "get"},
+		"context method takes precedence": {otel: otel("GET"), context: request("POST"), expected: "post"},
+		"non-string attribute":            {otel: otel(json.Number("1"))},
+		"no attribute":                    {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.otel != nil {
+				input["otel"] = test.otel
+			}
+			if test.context != nil {
+				input["context"] = test.context
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input})
+			require.NoError(t, err)
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			method, _ := output[0].Fields.GetValue("http.request.method")
+			assert.Equal(t, test.expected, method)
+		})
+	}
+}
+
+func TestTransactionEventOTelInvalid(t *testing.T) {
+	raw := map[string]interface{}{
+		"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+		"otel": map[string]interface{}{"attributes": "foo"},
+	}
+	_, err := DecodeEvent(model.Input{Raw: raw})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid otel")
+}
+
+func TestTransactionEventDecodeMaxTypeLength(t *testing.T) {
+	for name, test := range map[string]struct {
+		txType    string
+		config    model.Config
+		expected  string
+		truncated bool
+		err       string
+	}{
+		"within limit":        {txType: "request", config: model.Config{MaxTypeLength: 7}, expected: "request"},
+		"reject":              {txType: "request", config: model.Config{MaxTypeLength: 6}, err: "exceeds maximum length of 6 characters"},
+		"reject default":      {txType: strings.Repeat("a", 1025), err: "exceeds maximum length of 1024 characters"},
+		"truncate":            {txType: "request", config: model.Config{MaxTypeLength: 6, TruncateOverlong: true}, expected: "reque…", truncated: true},
+		"truncate default":    {txType: strings.Repeat("a", 1025), config: model.Config{TruncateOverlong: true}, expected: strings.Repeat("a", 1023) + "…", truncated: true},
+		"multi-byte":          {txType: "日本語のリクエスト", config: model.Config{MaxTypeLength: 9}, expected: "日本語のリクエスト"},
+		"truncate multi-byte": {txType: "日本語のリクエスト", config: model.Config{MaxTypeLength: 4, TruncateOverlong: true}, expected: "日本語…", truncated: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": test.txType, "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			transformable, err := DecodeEvent(model.Input{Raw: input, Config: test.config})
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			require.NoError(t, err)
+			event := transformable.(*Event)
+			assert.Equal(t, test.expected, event.Type)
+			assert.True(t, utf8.ValidString(event.Type))
+			assert.Equal(t, test.truncated, event.TypeTruncated)
+
+			output := event.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			truncated, _ := output[0].Fields.GetValue("transaction.type_truncated")
+			if test.truncated {
+				assert.Equal(t, true, truncated)
+			} else {
+				assert.Nil(t, truncated)
+			}
+		})
+	}
+}
+
+func TestTransactionEventDecodeTypeToCategory(t *testing.T) {
+	typeToCategory := map[string][]string{
+		"request": {"web"},
+		"db":      {"database", "network"},
+	}
+	for name, test := range map[string]struct {
+		txType   string
+		expected []string
+	}{
+		"mapped":          {txType: "request", expected: []string{"web"}},
+		"mapped multiple": {txType: "db", expected: []string{"database", "network"}},
+		"unmapped":        {txType: "scheduled"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"id": "123", "type": test.txType, "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+				},
+				Config: model.Config{TypeToCategory: typeToCategory},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, transformable.(*Event).EventCategory)
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			category, _ := output[0].Fields.GetValue("event.category")
+			if test.expected == nil {
+				assert.Nil(t, category)
+			} else {
+				assert.Equal(t, test.expected, category)
+			}
+		})
+	}
+}
+
+func TestTransactionEventDecodeIDs(t *testing.T) {
+	traceID, parentID := "0123456789abcdef0123456789abcdef", "abcdef0123456789"
+	for name, test := range map[string]struct {
+		traceID  string
+		parentID interface{}
+		strict   bool
+		err      string
+	}{
+		"valid":               {traceID: traceID, parentID: parentID, strict: true},
+		"valid, no parent":    {traceID: traceID, strict: true},
+		"trace_id too short":  {traceID: "0123456789abcdef", strict: true, err: "invalid trace_id for transaction event: expected 32 characters, got 16"},
+		"trace_id too long":   {traceID: traceID + "0", strict: true, err: "invalid trace_id for transaction event: expected 32 characters, got 33"},
+		"trace_id uppercase":  {traceID: strings.ToUpper(traceID), strict: true, err: "invalid trace_id for transaction event: expected lowercase hex characters"},
+		"parent_id too short": {traceID: traceID, parentID: "abcdef", strict: true, err: "invalid parent_id for transaction event: expected 16 characters, got 6"},
+		"parent_id too long":  {traceID: traceID, parentID: "abcdefabcdef01234567", strict: true, err: "invalid parent_id for transaction event: expected 16 characters, got 20"},
+		"parent_id uppercase": {traceID: traceID, parentID: "ABCDEF0123456789", strict: true, err: "invalid parent_id for transaction event: expected lowercase hex characters"},
+		"invalid, not strict": {traceID: "abc", parentID: "ABCDEF"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": test.traceID}
+			if test.parentID != nil {
+				input["parent_id"] = test.parentID
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input, Config: model.Config{StrictIDs: test.strict}})
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				assert.Nil(t, transformable)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.traceID, transformable.(*Event).TraceId)
+		})
+	}
+}
+
+func TestTransactionEventDecodeFAAS(t *testing.T) {
+	faas := map[string]interface{}{
+		"id":        "arn:aws:lambda:us-east-1:123456789012:function:my-function",
+		"coldstart": false,
+		"execution": "af9aa4-a6bb-4d9c-8fc0-5f4c7e5b2a91",
+		"trigger":   map[string]interface{}{"type": "http", "request_id": "abc-123"},
+	}
+	expected := &FAAS{
+		ID:               tests.StringPtr("arn:aws:lambda:us-east-1:123456789012:function:my-function"),
+		Coldstart:        new(bool),
+		Execution:        tests.StringPtr("af9aa4-a6bb-4d9c-8fc0-5f4c7e5b2a91"),
+		TriggerType:      tests.StringPtr("http"),
+		TriggerRequestID: tests.StringPtr("abc-123"),
+	}
+	for name, test := range map[string]struct {
+		input    map[string]interface{}
+		expected *FAAS
+		err      string
+	}{
+		"absent":       {input: map[string]interface{}{}},
+		"context":      {input: map[string]interface{}{"context": map[string]interface{}{"faas": faas}}, expected: expected},
+		"top-level":    {input: map[string]interface{}{"faas": faas}, expected: expected},
+		"invalid type": {input: map[string]interface{}{"faas": "lambda"}, err: "invalid faas"},
+		"invalid coldstart": {
+			input: map[string]interface{}{"faas": map[string]interface{}{"coldstart": "yes"}},
+			err:   "invalid faas",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			for k, v := range test.input {
+				input[k] = v
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input})
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, transformable.(*Event).FAAS)
+		})
+	}
+}
+
+func TestTransactionEventDecodeExcludeServices(t *testing.T) {
+	exclude := []string{"internal-*", "healthcheck"}
+	for name, test := range map[string]struct {
+		metadataService string
+		contextService  string
+		dropped         bool
+	}{
+		"matched glob":         {metadataService: "internal-cron", dropped: true},
+		"matched exact":        {metadataService: "healthcheck", dropped: true},
+		"unmatched":            {metadataService: "checkout"},
+		"matched context":      {metadataService: "checkout", contextService: "internal-cron", dropped: true},
+		"unmatched context":    {metadataService: "internal-cron", contextService: "checkout"},
+		"no service name":      {},
+		"prefix is not a glob": {metadataService: "my-internal-cron"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.contextService != "" {
+				input["context"] = map[string]interface{}{"service": map[string]interface{}{"name": test.contextService}}
+			}
+			var md metadata.Metadata
+			if test.metadataService != "" {
+				md.Service = &metadata.Service{Name: tests.StringPtr(test.metadataService)}
+			}
+			transformable, err := DecodeEvent(model.Input{
+				Raw:      input,
+				Metadata: md,
+				Config:   model.Config{ExcludeServices: exclude},
+			})
+			require.NoError(t, err)
+			if test.dropped {
+				assert.Nil(t, transformable)
+			} else {
+				assert.NotNil(t, transformable)
+			}
+		})
+	}
+}
+
+func TestTransactionEventDecodeProfilerStackTraceIDs(t *testing.T) {
+	ids := func(n int) []interface{} {
+		out := make([]interface{}, n)
+		for i := range out {
+			out[i] = fmt.Sprintf("id-%d", i)
+		}
+		return out
+	}
+	for name, test := range map[string]struct {
+		input    interface{}
+		max      int
+		expected int
+		err      string
+	}{
+		"absent":            {},
+		"below default cap": {input: ids(3), expected: 3},
+		"default cap":       {input: ids(1200), expected: 1000},
+		"configured cap":    {input: ids(5), max: 2, expected: 2},
+		"at configured cap": {input: ids(2), max: 2, expected: 2},
+		"empty id":          {input: []interface{}{"a", ""}, err: "invalid profiler_stack_trace_ids at index 1"},
+		"non-string id":     {input: []interface{}{1.0}, err: "invalid profiler_stack_trace_ids at index 0"},
+		"invalid overflow":  {input: []interface{}{"id-0", 1.0}, max: 1, expected: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.input != nil {
+				input["profiler_stack_trace_ids"] = test.input
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input, Config: model.Config{MaxStackTraceIDs: test.max}})
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			require.NoError(t, err)
+			event := transformable.(*Event)
+			assert.Len(t, event.ProfilerStackTraceIDs, test.expected)
+			for i, id := range event.ProfilerStackTraceIDs {
+				assert.Equal(t, fmt.Sprintf("id-%d", i), id)
+			}
+		})
+	}
+}
+
+func TestTransactionEventDecodeTracestate(t *testing.T) {
+	member := "vendor=" + strings.Repeat("a", 93) // 100 characters
+	long := strings.Repeat(member+",", 5) + member
+	for name, test := range map[string]struct {
+		input     map[string]interface{}
+		expected  *string
+		truncated bool
+	}{
+		"absent":    {input: map[string]interface{}{}},
+		"top-level": {input: map[string]interface{}{"tracestate": "es=s:0.5,rojo=00f067aa0ba902b7"}, expected: tests.StringPtr("es=s:0.5,rojo=00f067aa0ba902b7")},
+		"context": {
+			input:    map[string]interface{}{"context": map[string]interface{}{"trace_state": "rojo=00f067aa0ba902b7"}},
+			expected: tests.StringPtr("rojo=00f067aa0ba902b7"),
+		},
+		"top-level precedence": {
+			input: map[string]interface{}{
+				"tracestate": "es=s:0.5",
+				"context":    map[string]interface{}{"trace_state": "rojo=00f067aa0ba902b7"},
+			},
+			expected: tests.StringPtr("es=s:0.5"),
+		},
+		"oversized": {
+			input:     map[string]interface{}{"tracestate": long},
+			expected:  tests.StringPtr(long[:5*len(member)+4]),
+			truncated: true,
+		},
+		"oversized single member": {
+			input:     map[string]interface{}{"tracestate": "vendor=" + strings.Repeat("a", 600)},
+			truncated: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			for k, v := range test.input {
+				input[k] = v
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input})
+			require.NoError(t, err)
+			event := transformable.(*Event)
+			assert.Equal(t, test.expected, event.Tracestate)
+			assert.Equal(t, test.truncated, event.TracestateTruncated)
+		})
+	}
+}
+
+func TestTransactionEventDecodeECSDurationOverflow(t *testing.T) {
+	for name, duration := range map[string]float64{
+		"max float":         math.MaxFloat64,
+		"overflows ns":      1e13,
+		"overflows ns & us": 1e16,
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"id": "123", "type": "tx", "duration": duration, "trace_id": "0123456789abcdef0123456789abcdef",
+				},
+				Config: model.Config{EmitECSDuration: true},
+			})
+			require.NoError(t, err)
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			fields := output[0].Fields
+			eventDuration, _ := fields.GetValue("event.duration")
+			assert.Equal(t, int64(math.MaxInt64), eventDuration)
+			durationUs, _ := fields.GetValue("transaction.duration.us")
+			assert.Equal(t, int(utility.Float64ToInt64(duration*1000)), durationUs)
+			assert.True(t, durationUs.(int) > 0)
+		})
+	}
+}
+
+func TestTransactionEventDecodeECSDuration(t *testing.T) {
+	for name, test := range map[string]struct {
+		duration float64
+		emit     bool
+		expected interface{}
+	}{
+		"fractional ms":  {duration: 1.67, emit: true, expected: int64(1670000)},
+		"sub-ns rounded": {duration: 0.0000015, emit: true, expected: int64(2)},
+		"seconds":        {duration: 2500, emit: true, expected: int64(2500000000)},
+		"not emitted":    {duration: 1.67},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"id": "123", "type": "tx", "duration": test.duration, "trace_id": "0123456789abcdef0123456789abcdef",
+				},
+				Config: model.Config{EmitECSDuration: test.emit},
+			})
+			require.NoError(t, err)
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			fields := output[0].Fields
+			durationUs := fields["transaction"].(common.MapStr)["duration"].(common.MapStr)["us"]
+			assert.Equal(t, int(test.duration*1000), durationUs)
+			eventDuration, _ := fields.GetValue("event.duration")
+			if test.expected == nil {
+				assert.Nil(t, eventDuration)
+				return
+			}
+			assert.Equal(t, test.expected, eventDuration)
+			assert.InDelta(t, float64(durationUs.(int))*1000, float64(eventDuration.(int64)), 1000)
+		})
+	}
+}
+
+func TestTransactionEventDecodeHumanDuration(t *testing.T) {
+	for name, test := range map[string]struct {
+		duration float64
+		emit     bool
+		expected interface{}
+	}{
+		"sub-ms":      {duration: 0.25, emit: true, expected: "250µs"},
+		"ms":          {duration: 1.67, emit: true, expected: "1.67ms"},
+		"seconds":     {duration: 2500, emit: true, expected: "2.5s"},
+		"not emitted": {duration: 1.67},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"id": "123", "type": "tx", "duration": test.duration, "trace_id": "0123456789abcdef0123456789abcdef",
+				},
+				Config: model.Config{EmitHumanDuration: test.emit},
+			})
+			require.NoError(t, err)
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			duration := output[0].Fields["transaction"].(common.MapStr)["duration"].(common.MapStr)
+			assert.Equal(t, int(test.duration*1000), duration["us"])
+			assert.Equal(t, test.expected, duration["human"])
+		})
+	}
+}
+
+func TestTransactionEventDecodeDurationSummary(t *testing.T) {
+	for name, test := range map[string]struct {
+		sampleRate interface{}
+		emit       bool
+		count      interface{}
+		sum        interface{}
+	}{
+		"unsampled":   {emit: true, count: int64(1), sum: common.MapStr{"us": int64(12500)}},
+		"weight 1":    {sampleRate: 1.0, emit: true, count: int64(1), sum: common.MapStr{"us": int64(12500)}},
+		"weight 10":   {sampleRate: 0.1, emit: true, count: int64(10), sum: common.MapStr{"us": int64(125000)}},
+		"weight 0.5":  {sampleRate: 2.0, emit: true, count: int64(1), sum: common.MapStr{"us": int64(6250)}},
+		"not emitted": {sampleRate: 0.1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"id": "123", "type": "tx", "duration": 12.5, "trace_id": "0123456789abcdef0123456789abcdef",
+			}
+			if test.sampleRate != nil {
+				raw["sample_rate"] = test.sampleRate
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: raw, Config: model.Config{EmitDurationSummary: test.emit}})
+			require.NoError(t, err)
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			duration := output[0].Fields["transaction"].(common.MapStr)["duration"].(common.MapStr)
+			assert.Equal(t, 12500, duration["us"])
+			assert.Equal(t, test.count, duration["count"])
+			assert.Equal(t, test.sum, duration["sum"])
+		})
+	}
+}
+
+func TestTransactionEventSampledAsInt(t *testing.T) {
+	for name, test := range map[string]struct {
+		sampled  bool
+		asInt    bool
+		expected interface{}
+	}{
+		"true":         {sampled: true, expected: true},
+		"false":        {sampled: false, expected: false},
+		"true as int":  {sampled: true, asInt: true, expected: 1},
+		"false as int": {sampled: false, asInt: true, expected: 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"id": "123", "type": "tx", "duration": 1.0, "sampled": test.sampled,
+					"trace_id": "0123456789abcdef0123456789abcdef",
+				},
+				Config: model.Config{SampledAsInt: test.asInt},
+			})
+			require.NoError(t, err)
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			assert.Equal(t, test.expected, output[0].Fields["transaction"].(common.MapStr)["sampled"])
+		})
+	}
+}
+
+func TestTransactionEventDecodeLinks(t *testing.T) {
+	traceID, spanID := "0af7651916cd43dd8448eb211c80319c", "b7ad6b7169203331"
+	link := func(traceID, spanID interface{}) map[string]interface{} {
+		return map[string]interface{}{"trace_id": traceID, "span_id": spanID}
+	}
+	for name, test := range map[string]struct {
+		links    interface{}
+		expected []SpanLink
+		err      string
+	}{
+		"absent": {},
+		"empty":  {links: []interface{}{}},
+		"valid": {
+			links:    []interface{}{link(traceID, spanID), link("4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")},
+			expected: []SpanLink{{TraceId: traceID, SpanId: spanID}, {TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7"}},
+		},
+		"invalid type":          {links: "foo", err: utility.ErrFetch.Error()},
+		"invalid entry type":    {links: []interface{}{link(traceID, spanID), "foo"}, err: "invalid type for link at index 1"},
+		"missing span_id":       {links: []interface{}{map[string]interface{}{"trace_id": traceID}}, err: "invalid link at index 0"},
+		"short trace_id":        {links: []interface{}{link("0af765", spanID)}, err: "invalid link at index 0: trace_id must be 32 hex characters"},
+		"non-hex span_id":       {links: []interface{}{link(traceID, spanID), link(traceID, "b7ad6b716920333z")}, err: "invalid link at index 1: span_id must be 16 hex characters"},
+		"non-string identifier": {links: []interface{}{link(traceID, 123)}, err: "invalid link at index 0"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.links != nil {
+				input["links"] = test.links
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input})
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				assert.Nil(t, transformable)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, transformable.(*Event).Links)
+		})
+	}
+}
+
+func TestTransactionEventDecodeDroppedSpansStats(t *testing.T) {
+	entry := func(resource, outcome string, count, sum interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"destination_service_resource": resource,
+			"outcome":                      outcome,
+			"duration":                     map[string]interface{}{"count": count, "sum": map[string]interface{}{"us": sum}},
+		}
+	}
+	for name, test := range map[string]struct {
+		stats    interface{}
+		expected []DroppedSpanStats
+		err      string
+	}{
+		"absent": {},
+		"empty":  {stats: []interface{}{}},
+		"valid": {
+			stats: []interface{}{entry("mysql", "success", 10.0, 1200.0), entry("redis", "failure", json.Number("2"), json.Number("35"))},
+			expected: []DroppedSpanStats{
+				{DestinationServiceResource: "mysql", Outcome: "success", DurationCount: 10, DurationSumUs: 1200},
+				{DestinationServiceResource: "redis", Outcome: "failure", DurationCount: 2, DurationSumUs: 35},
+			},
+		},
+		"invalid type":       {stats: "foo", err: utility.ErrFetch.Error()},
+		"invalid entry type": {stats: []interface{}{"foo"}, err: "invalid type for dropped_spans_stats at index 0"},
+		"missing sum":        {stats: []interface{}{map[string]interface{}{"destination_service_resource": "mysql", "outcome": "success", "duration": map[string]interface{}{"count": 1.0}}}, err: "invalid dropped_spans_stats at index 0"},
+		"invalid outcome":    {stats: []interface{}{entry("mysql", "bad", 1.0, 1.0)}, err: `invalid dropped_spans_stats at index 0: invalid outcome "bad"`},
+		"negative count":     {stats: []interface{}{entry("mysql", "success", 1.0, 1.0), entry("mysql", "success", -1.0, 1.0)}, err: "invalid dropped_spans_stats at index 1: negative duration.count -1"},
+		"negative sum":       {stats: []interface{}{entry("mysql", "success", 1.0, -5.0)}, err: "invalid dropped_spans_stats at index 0: negative duration.sum.us -5"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.stats != nil {
+				input["dropped_spans_stats"] = test.stats
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input})
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				assert.Nil(t, transformable)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, transformable.(*Event).DroppedSpansStats)
+		})
+	}
+}
+
+func TestTransactionEventDecodeCategory(t *testing.T) {
+	for name, test := range map[string]struct {
+		trType   string
+		result   interface{}
+		emit     bool
+		expected *string
+	}{
+		"http result class":     {trType: "request", result: "HTTP 2xx", emit: true, expected: tests.StringPtr("request:2xx")},
+		"http status code":      {trType: "request", result: "503", emit: true, expected: tests.StringPtr("request:5xx")},
+		"http prefixed code":    {trType: "request", result: "HTTP 404", emit: true, expected: tests.StringPtr("request:4xx")},
+		"lowercase http class":  {trType: "request", result: "http 2XX", emit: true, expected: tests.StringPtr("request:2xx")},
+		"mixed case http class": {trType: "request", result: "Http 2Xx", emit: true, expected: tests.StringPtr("request:2xx")},
+		"lowercase http code":   {trType: "request", result: "http 500", emit: true, expected: tests.StringPtr("request:5xx")},
+		"messaging result":      {trType: "messaging", result: "Success", emit: true, expected: tests.StringPtr("messaging:success")},
+		"no result":             {trType: "messaging", emit: true, expected: tests.StringPtr("messaging")},
+		"non-status digits":     {trType: "job", result: "999", emit: true, expected: tests.StringPtr("job:999")},
+		"EmitCategory disabled": {trType: "request", result: "HTTP 2xx"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": test.trType, "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.result != nil {
+				input["result"] = test.result
+			}
+			transformable, err := DecodeEvent(model.Input{
+				Raw:    input,
+				Config: model.Config{EmitTransactionCategory: test.emit},
+			})
+			require.NoError(t, err)
+			event := transformable.(*Event)
+			assert.Equal(t, test.expected, event.Category)
+
+			output := event.Transform(context.Background(), &transform.Context{})
+			category, _ := output[0].Fields.GetValue("transaction.category")
+			if test.expected != nil {
+				assert.Equal(t, *test.expected, category)
+			} else {
+				assert.Nil(t, category)
+			}
+		})
+	}
+}
+
+func TestTransactionEventDecodeNormalizeHTTPResult(t *testing.T) {
+	for name, test := range map[string]struct {
+		result     interface{}
+		statusCode interface{}
+		normalize  bool
+		expected   interface{}
+	}{
+		"status code result":  {result: "200", statusCode: json.Number("200"), normalize: true, expected: "HTTP 2xx"},
+		"free-form result":    {result: "OK", statusCode: json.Number("404"), normalize: true, expected: "HTTP 4xx"},
+		"no result":           {statusCode: json.Number("503"), normalize: true, expected: "HTTP 5xx"},
+		"no status code":      {result: "success", normalize: true, expected: "success"},
+		"invalid status code": {result: "weird", statusCode: json.Number("999"), normalize: true, expected: "weird"},
+		"not normalized":      {result: "200", statusCode: json.Number("200"), expected: "200"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]interface{}{"id": "123", "type": "request", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.result != nil {
+				raw["result"] = test.result
+			}
+			if test.statusCode != nil {
+				raw["context"] = map[string]interface{}{"response": map[string]interface{}{"status_code": test.statusCode}}
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: raw, Config: model.Config{NormalizeHTTPResult: test.normalize}})
+			require.NoError(t, err)
+			event := transformable.(*Event)
+			if test.result != nil {
+				// the raw result remains available
+				assert.Equal(t, test.result, *event.Result)
+			}
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			assert.Equal(t, test.expected, output[0].Fields["transaction"].(common.MapStr)["result"])
+		})
+	}
+}
+
+func TestTransactionEventDecodeSampleRate(t *testing.T) {
+	for name, test := range map[string]struct {
+		sampleRate          interface{}
+		expectedRate        *float64
+		representativeCount float64
+		err                 string
+	}{
+		"absent":   {},
+		"full":     {sampleRate: json.Number("1"), expectedRate: newFloat64(1), representativeCount: 1},
+		"quarter":  {sampleRate: json.Number("0.25"), expectedRate: newFloat64(0.25), representativeCount: 4},
+		"float":    {sampleRate: 0.5, expectedRate: newFloat64(0.5), representativeCount: 2},
+		"zero":     {sampleRate: json.Number("0"), err: "invalid sample_rate 0"},
+		"negative": {sampleRate: json.Number("-0.5"), err: "invalid sample_rate -0.5"},
+		"invalid":  {sampleRate: "half", err: utility.ErrFetch.Error()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.sampleRate != nil {
+				input["sample_rate"] = test.sampleRate
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input})
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			require.NoError(t, err)
+			event := transformable.(*Event)
+			assert.Equal(t, test.expectedRate, event.SampleRate)
+			assert.Equal(t, test.representativeCount, event.RepresentativeCount)
+		})
+	}
+}
+
+func newFloat64(f float64) *float64 {
+	return &f
+}
+
 func TestTransactionDecodeRUMV3Marks(t *testing.T) {
 	// unknown fields are ignored
 	input := map[string]interface{}{
@@ -75,108 +1401,242 @@ func TestTransactionDecodeRUMV3Marks(t *testing.T) {
 				"dc":  1.2,
 			},
 		},
-	}
-	event := &Event{}
-	transformable, err := decodeRUMV3Marks(event, input, model.Config{HasShortFieldNames: true})
-	require.Nil(t, err)
-
-	var f = 1.2
-	assert.Equal(t, common.MapStr{
-		"agent":            common.MapStr{"domComplete": &f},
-		"navigationTiming": common.MapStr{"domComplete": &f},
-	}, transformable.(*Event).Marks)
-}
-
-func TestTransactionEventDecode(t *testing.T) {
-	id, trType, name, result := "123", "type", "foo()", "555"
-	requestTime := time.Now()
-	timestampParsed := time.Date(2017, 5, 30, 18, 53, 27, 154*1e6, time.UTC)
-	timestampEpoch := json.Number(fmt.Sprintf("%d", timestampParsed.UnixNano()/1000))
-
-	traceId, parentId := "0147258369012345abcdef0123456789", "abcdef0123456789"
-	dropped, started, duration := 12, 6, 1.67
-	name, userId, email, userIp := "jane", "abc123", "j@d.com", "127.0.0.1"
-	url, referer, origUrl := "https://mypage.com", "http:mypage.com", "127.0.0.1"
-	marks := map[string]interface{}{"k": "b"}
-	sampled := true
-	labels := model.Labels{"foo": "bar"}
-	ua := "go-1.1"
-	user := metadata.User{Name: &name, Email: &email, IP: net.ParseIP(userIp), Id: &userId, UserAgent: &ua}
-	page := model.Page{Url: &url, Referer: &referer}
-	request := model.Req{Method: "post", Socket: &model.Socket{}, Headers: http.Header{"User-Agent": []string{ua}}}
-	response := model.Resp{Finished: new(bool), MinimalResp: model.MinimalResp{Headers: http.Header{"Content-Type": []string{"text/html"}}}}
-	h := model.Http{Request: &request, Response: &response}
-	ctxUrl := model.Url{Original: &origUrl}
-	custom := model.Custom{"abc": 1}
-	metadata := metadata.Metadata{Service: &metadata.Service{Name: tests.StringPtr("foo")}}
-
-	for name, test := range map[string]struct {
-		input interface{}
-		cfg   model.Config
-		err   string
-		e     *Event
-	}{
-		"no timestamp specified, request time used": {
+	}
+	event := &Event{}
+	transformable, err := decodeRUMV3Marks(event, input, model.Config{HasShortFieldNames: true})
+	require.Nil(t, err)
+
+	var f = 1.2
+	assert.Equal(t, common.MapStr{
+		"agent":            common.MapStr{"domComplete": &f},
+		"navigationTiming": common.MapStr{"domComplete": &f},
+	}, transformable.(*Event).Marks)
+}
+
+func TestTransactionEventDecode(t *testing.T) {
+	id, trType, name, result := "123", "type", "foo()", "555"
+	requestTime := time.Now()
+	timestampParsed := time.Date(2017, 5, 30, 18, 53, 27, 154*1e6, time.UTC)
+	timestampEpoch := json.Number(fmt.Sprintf("%d", timestampParsed.UnixNano()/1000))
+
+	traceId, parentId := "0147258369012345abcdef0123456789", "abcdef0123456789"
+	dropped, started, duration := 12, 6, 1.67
+	name, userId, email, userIp := "jane", "abc123", "j@d.com", "127.0.0.1"
+	url, referer, origUrl := "https://mypage.com", "http:mypage.com", "127.0.0.1"
+	marks := map[string]interface{}{"k": "b"}
+	sampled := true
+	labels := model.Labels{"foo": "bar"}
+	ua := "go-1.1"
+	user := metadata.User{Name: &name, Email: &email, IP: net.ParseIP(userIp), Id: &userId, UserAgent: &ua}
+	page := model.Page{Url: &url, Referer: &referer}
+	request := model.Req{Method: "post", Socket: &model.Socket{}, Headers: http.Header{"User-Agent": []string{ua}}}
+	response := model.Resp{Finished: new(bool), MinimalResp: model.MinimalResp{Headers: http.Header{"Content-Type": []string{"text/html"}}}}
+	h := model.Http{Request: &request, Response: &response}
+	ctxUrl := model.Url{Original: &origUrl}
+	custom := model.Custom{"abc": 1}
+	metadata := metadata.Metadata{Service: &metadata.Service{Name: tests.StringPtr("foo")}}
+
+	for name, test := range map[string]struct {
+		input interface{}
+		cfg   model.Config
+		err   string
+		e     *Event
+	}{
+		"no timestamp specified, request time used": {
+			input: map[string]interface{}{
+				"id": id, "type": trType, "name": name, "duration": duration, "trace_id": traceId,
+			},
+			e: &Event{
+				Metadata:  metadata,
+				Id:        id,
+				Type:      trType,
+				Name:      &name,
+				TraceId:   traceId,
+				Duration:  duration,
+				Timestamp: requestTime,
+			},
+		},
+		"event experimental=true, no experimental payload": {
+			input: map[string]interface{}{
+				"id": id, "type": trType, "name": name, "duration": duration, "trace_id": traceId,
+				"timestamp": timestampEpoch, "context": map[string]interface{}{"foo": "bar"},
+			},
+			cfg: model.Config{Experimental: true},
+			e: &Event{
+				Metadata:  metadata,
+				Id:        id,
+				Type:      trType,
+				Name:      &name,
+				TraceId:   traceId,
+				Duration:  duration,
+				Timestamp: timestampParsed,
+			},
+		},
+		"event experimental=false": {
+			input: map[string]interface{}{
+				"id": id, "type": trType, "name": name, "duration": duration, "trace_id": traceId, "timestamp": timestampEpoch,
+				"context": map[string]interface{}{"experimental": map[string]interface{}{"foo": "bar"}},
+			},
+			cfg: model.Config{Experimental: false},
+			e: &Event{
+				Metadata:  metadata,
+				Id:        id,
+				Type:      trType,
+				Name:      &name,
+				TraceId:   traceId,
+				Duration:  duration,
+				Timestamp: timestampParsed,
+			},
+		},
+		"event experimental=true": {
+			input: map[string]interface{}{
+				"id": id, "type": trType, "name": name, "duration": duration, "trace_id": traceId, "timestamp": timestampEpoch,
+				"context": map[string]interface{}{"experimental": map[string]interface{}{"foo": "bar"}},
+			},
+			cfg: model.Config{Experimental: true},
+			e: &Event{
+				Metadata:     metadata,
+				Id:           id,
+				Type:         trType,
+				Name:         &name,
+				TraceId:      traceId,
+				Duration:     duration,
+				Timestamp:    timestampParsed,
+				Experimental: map[string]interface{}{"foo": "bar"},
+			},
+		},
+		"result with surrounding whitespace, TrimResult=true": {
+			input: map[string]interface{}{
+				"id": id, "type": trType, "duration": duration, "trace_id": traceId,
+				"timestamp": timestampEpoch, "result": " 200 ",
+			},
+			cfg: model.Config{TrimResult: true},
+			e: &Event{
+				Metadata:  metadata,
+				Id:        id,
+				Type:      trType,
+				Result:    tests.StringPtr("200"),
+				TraceId:   traceId,
+				Duration:  duration,
+				Timestamp: timestampParsed,
+			},
+		},
+		"result with surrounding whitespace, TrimResult=false": {
+			input: map[string]interface{}{
+				"id": id, "type": trType, "duration": duration, "trace_id": traceId,
+				"timestamp": timestampEpoch, "result": " 200 ",
+			},
+			e: &Event{
+				Metadata:  metadata,
+				Id:        id,
+				Type:      trType,
+				Result:    tests.StringPtr(" 200 "),
+				TraceId:   traceId,
+				Duration:  duration,
+				Timestamp: timestampParsed,
+			},
+		},
+		"clean result, TrimResult=true": {
+			input: map[string]interface{}{
+				"id": id, "type": trType, "duration": duration, "trace_id": traceId,
+				"timestamp": timestampEpoch, "result": "HTTP 2xx",
+			},
+			cfg: model.Config{TrimResult: true},
+			e: &Event{
+				Metadata:  metadata,
+				Id:        id,
+				Type:      trType,
+				Result:    tests.StringPtr("HTTP 2xx"),
+				TraceId:   traceId,
+				Duration:  duration,
+				Timestamp: timestampParsed,
+			},
+		},
+		"custom context with array values": {
+			input: map[string]interface{}{
+				"id": id, "type": trType, "duration": duration, "trace_id": traceId, "timestamp": timestampEpoch,
+				"context": map[string]interface{}{"custom": map[string]interface{}{
+					"tags":   []interface{}{"a", "b"},
+					"nested": map[string]interface{}{"ids": []interface{}{json.Number("1"), json.Number("2")}},
+				}},
+			},
+			e: &Event{
+				Metadata:  metadata,
+				Id:        id,
+				Type:      trType,
+				TraceId:   traceId,
+				Duration:  duration,
+				Timestamp: timestampParsed,
+				Custom: &model.Custom{
+					"tags":   []interface{}{"a", "b"},
+					"nested": map[string]interface{}{"ids": []interface{}{json.Number("1"), json.Number("2")}},
+				},
+			},
+		},
+		"empty strings, DropEmptyStrings=true": {
 			input: map[string]interface{}{
-				"id": id, "type": trType, "name": name, "duration": duration, "trace_id": traceId,
+				"id": id, "type": trType, "duration": duration, "trace_id": traceId, "timestamp": timestampEpoch,
+				"name": "", "result": "", "parent_id": "",
 			},
+			cfg: model.Config{DropEmptyStrings: true},
 			e: &Event{
 				Metadata:  metadata,
 				Id:        id,
 				Type:      trType,
-				Name:      &name,
 				TraceId:   traceId,
 				Duration:  duration,
-				Timestamp: requestTime,
+				Timestamp: timestampParsed,
 			},
 		},
-		"event experimental=true, no experimental payload": {
+		"empty strings, DropEmptyStrings=false": {
 			input: map[string]interface{}{
-				"id": id, "type": trType, "name": name, "duration": duration, "trace_id": traceId,
-				"timestamp": timestampEpoch, "context": map[string]interface{}{"foo": "bar"},
+				"id": id, "type": trType, "duration": duration, "trace_id": traceId, "timestamp": timestampEpoch,
+				"name": "", "result": "", "parent_id": "",
 			},
-			cfg: model.Config{Experimental: true},
 			e: &Event{
 				Metadata:  metadata,
 				Id:        id,
 				Type:      trType,
-				Name:      &name,
+				Name:      tests.StringPtr(""),
+				Result:    tests.StringPtr(""),
+				ParentId:  tests.StringPtr(""),
 				TraceId:   traceId,
 				Duration:  duration,
 				Timestamp: timestampParsed,
 			},
 		},
-		"event experimental=false": {
+		"blank strings, DropEmptyStrings=true": {
 			input: map[string]interface{}{
-				"id": id, "type": trType, "name": name, "duration": duration, "trace_id": traceId, "timestamp": timestampEpoch,
-				"context": map[string]interface{}{"experimental": map[string]interface{}{"foo": "bar"}},
+				"id": id, "type": trType, "duration": duration, "trace_id": traceId, "timestamp": timestampEpoch,
+				"name": " ", "result": " \t",
 			},
-			cfg: model.Config{Experimental: false},
+			cfg: model.Config{DropEmptyStrings: true, CollapseNameWhitespace: true, TrimResult: true},
 			e: &Event{
 				Metadata:  metadata,
 				Id:        id,
 				Type:      trType,
-				Name:      &name,
+				Name:      tests.StringPtr(" "),
 				TraceId:   traceId,
 				Duration:  duration,
 				Timestamp: timestampParsed,
 			},
 		},
-		"event experimental=true": {
+		"non-empty strings, DropEmptyStrings=true": {
 			input: map[string]interface{}{
-				"id": id, "type": trType, "name": name, "duration": duration, "trace_id": traceId, "timestamp": timestampEpoch,
-				"context": map[string]interface{}{"experimental": map[string]interface{}{"foo": "bar"}},
+				"id": id, "type": trType, "duration": duration, "trace_id": traceId, "timestamp": timestampEpoch,
+				"name": name, "result": result, "parent_id": parentId,
 			},
-			cfg: model.Config{Experimental: true},
+			cfg: model.Config{DropEmptyStrings: true},
 			e: &Event{
-				Metadata:     metadata,
-				Id:           id,
-				Type:         trType,
-				Name:         &name,
-				TraceId:      traceId,
-				Duration:     duration,
-				Timestamp:    timestampParsed,
-				Experimental: map[string]interface{}{"foo": "bar"},
+				Metadata:  metadata,
+				Id:        id,
+				Type:      trType,
+				Name:      &name,
+				Result:    &result,
+				ParentId:  &parentId,
+				TraceId:   traceId,
+				Duration:  duration,
+				Timestamp: timestampParsed,
 			},
 		},
 		"messaging event": {
@@ -202,7 +1662,7 @@ func TestTransactionEventDecode(t *testing.T) {
 				Message: &model.Message{
 					QueueName: tests.StringPtr("order"),
 					Body:      tests.StringPtr("confirmed"),
-					Headers:   http.Header{"Internal": []string{"false"}},
+					Headers:   http.Header{"internal": []string{"false"}},
 					AgeMillis: tests.IntPtr(1577958057123),
 				},
 			},
@@ -274,9 +1734,39 @@ func TestTransactionEventDecode(t *testing.T) {
 	}
 }
 
+func TestTransactionEventDecodeMaxUserNameLength(t *testing.T) {
+	for name, test := range map[string]struct {
+		username string
+		max      int
+		expected string
+	}{
+		"short name":         {username: "jane", max: 5, expected: "jane"},
+		"name at limit":      {username: "janes", max: 5, expected: "janes"},
+		"long name":          {username: "jane doe", max: 5, expected: "jane "},
+		"long multibyte":     {username: "jürgen müller", max: 4, expected: "jürg"},
+		"no limit":           {username: "jane doe", max: 0, expected: "jane doe"},
+		"multibyte at limit": {username: "日本語", max: 3, expected: "日本語"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+					"context": map[string]interface{}{"user": map[string]interface{}{"username": test.username}},
+				},
+				Config: model.Config{MaxUserNameLength: test.max},
+			})
+			require.NoError(t, err)
+			event := transformable.(*Event)
+			require.NotNil(t, event.User)
+			assert.Equal(t, test.expected, *event.User.Name)
+		})
+	}
+}
+
 func TestEventTransform(t *testing.T) {
 	id := "123"
 	result := "tx result"
+	outcome := "success"
 	sampled := false
 	dropped, startedSpans := 5, 14
 	name := "mytransaction"
@@ -324,53 +1814,526 @@ func TestEventTransform(t *testing.T) {
 				"span_count": common.MapStr{"started": 14},
 				"sampled":    true,
 			},
-			Msg: "SpanCount only contains `started`",
+			Msg: "SpanCount only contains `started`",
+		},
+		{
+			Event: Event{
+				Id:        id,
+				Type:      "tx",
+				Duration:  65.98,
+				SpanCount: SpanCount{Dropped: &dropped},
+			},
+			Output: common.MapStr{
+				"id":         id,
+				"type":       "tx",
+				"duration":   common.MapStr{"us": 65980},
+				"span_count": common.MapStr{"dropped": 5},
+				"sampled":    true,
+			},
+			Msg: "SpanCount only contains `dropped`",
+		},
+		{
+			Event: Event{
+				Id:        id,
+				Name:      &name,
+				Type:      "tx",
+				Result:    &result,
+				Outcome:   &outcome,
+				Timestamp: time.Now(),
+				Duration:  65.98,
+				Sampled:   &sampled,
+				SpanCount: SpanCount{Started: &startedSpans, Dropped: &dropped},
+			},
+			Output: common.MapStr{
+				"id":         id,
+				"name":       "mytransaction",
+				"type":       "tx",
+				"result":     "tx result",
+				"outcome":    "success",
+				"duration":   common.MapStr{"us": 65980},
+				"span_count": common.MapStr{"started": 14, "dropped": 5},
+				"sampled":    false,
+			},
+			Msg: "Full Event",
+		},
+	}
+
+	tctx := &transform.Context{}
+
+	for idx, test := range tests {
+		output := test.Event.Transform(context.Background(), tctx)
+		assert.Equal(t, test.Output, output[0].Fields["transaction"], fmt.Sprintf("Failed at idx %v; %s", idx, test.Msg))
+	}
+}
+
+func TestEventTransformCustomArrays(t *testing.T) {
+	event := Event{
+		Custom: &model.Custom{
+			"tags":    []interface{}{"a", "b"},
+			"objects": []interface{}{map[string]interface{}{"k": "v"}},
+			"nested":  map[string]interface{}{"ids": []interface{}{1, 2}},
+		},
+	}
+	output := event.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	assert.Equal(t, common.MapStr{
+		"tags":    []interface{}{"a", "b"},
+		"objects": []interface{}{map[string]interface{}{"k": "v"}},
+		"nested":  map[string]interface{}{"ids": []interface{}{1, 2}},
+	}, output[0].Fields["transaction"].(common.MapStr)["custom"])
+}
+
+func TestEventTransformSampleRate(t *testing.T) {
+	sampleRate := 0.2
+	event := Event{SampleRate: &sampleRate, RepresentativeCount: 5}
+	output := event.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	tx := output[0].Fields["transaction"].(common.MapStr)
+	assert.Equal(t, 0.2, tx["sample_rate"])
+	assert.Equal(t, int64(5), tx["representative_count"])
+
+	output = (&Event{}).Transform(context.Background(), &transform.Context{})
+	tx = output[0].Fields["transaction"].(common.MapStr)
+	assert.NotContains(t, tx, "sample_rate")
+	assert.NotContains(t, tx, "representative_count")
+}
+
+func TestEventTransformProfilerStackTraceIDs(t *testing.T) {
+	event := Event{ProfilerStackTraceIDs: []string{"a", "b"}}
+	output := event.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	tx := output[0].Fields["transaction"].(common.MapStr)
+	assert.Equal(t, []string{"a", "b"}, tx["profiler_stack_trace_ids"])
+
+	output = (&Event{}).Transform(context.Background(), &transform.Context{})
+	assert.NotContains(t, output[0].Fields["transaction"], "profiler_stack_trace_ids")
+}
+
+func TestEventTransformTracestate(t *testing.T) {
+	event := Event{Tracestate: tests.StringPtr("es=s:0.5"), TracestateTruncated: true}
+	output := event.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	tx := output[0].Fields["transaction"].(common.MapStr)
+	assert.Equal(t, "es=s:0.5", tx["tracestate"])
+	assert.Equal(t, true, tx["tracestate_truncated"])
+
+	output = (&Event{}).Transform(context.Background(), &transform.Context{})
+	tx = output[0].Fields["transaction"].(common.MapStr)
+	assert.NotContains(t, tx, "tracestate")
+	assert.NotContains(t, tx, "tracestate_truncated")
+}
+
+func TestEventTransformDroppedSpansStats(t *testing.T) {
+	event := Event{DroppedSpansStats: []DroppedSpanStats{
+		{DestinationServiceResource: "mysql", Outcome: "success", DurationCount: 10, DurationSumUs: 1200},
+	}}
+	output := event.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	tx := output[0].Fields["transaction"].(common.MapStr)
+	assert.Equal(t, []common.MapStr{{
+		"destination_service_resource": "mysql",
+		"outcome":                      "success",
+		"duration":                     common.MapStr{"count": 10, "sum": common.MapStr{"us": int64(1200)}},
+	}}, tx["dropped_spans_stats"])
+
+	output = (&Event{}).Transform(context.Background(), &transform.Context{})
+	assert.NotContains(t, output[0].Fields["transaction"], "dropped_spans_stats")
+}
+
+func TestEventTransformLinks(t *testing.T) {
+	event := Event{Links: []SpanLink{
+		{TraceId: "0af7651916cd43dd8448eb211c80319c", SpanId: "b7ad6b7169203331"},
+		{TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7"},
+	}}
+	output := event.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	assert.Equal(t, common.MapStr{"links": []common.MapStr{
+		{"trace": common.MapStr{"id": "0af7651916cd43dd8448eb211c80319c"}, "span": common.MapStr{"id": "b7ad6b7169203331"}},
+		{"trace": common.MapStr{"id": "4bf92f3577b34da6a3ce929d0e0e4736"}, "span": common.MapStr{"id": "00f067aa0ba902b7"}},
+	}}, output[0].Fields["span"])
+
+	output = (&Event{}).Transform(context.Background(), &transform.Context{})
+	assert.NotContains(t, output[0].Fields, "span")
+}
+
+func TestEventTransformURLExtension(t *testing.T) {
+	for name, test := range map[string]struct {
+		url      *model.Url
+		expected interface{}
+	}{
+		"script":           {url: &model.Url{Path: tests.StringPtr("/static/app.min.js")}, expected: "js"},
+		"image":            {url: &model.Url{Path: tests.StringPtr("/img/logo.png")}, expected: "png"},
+		"no extension":     {url: &model.Url{Path: tests.StringPtr("/api/users")}},
+		"dot in directory": {url: &model.Url{Path: tests.StringPtr("/v1.2/users/")}},
+		"trailing dot":     {url: &model.Url{Path: tests.StringPtr("/file.")}},
+		"no path":          {url: &model.Url{Domain: tests.StringPtr("example.com")}},
+		"no url":           {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			event := Event{Url: test.url}
+			output := event.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			extension, _ := output[0].Fields.GetValue("url.extension")
+			assert.Equal(t, test.expected, extension)
+		})
+	}
+}
+
+func TestEventTransformPageDomain(t *testing.T) {
+	for name, test := range map[string]struct {
+		page     *model.Page
+		url      *model.Url
+		expected interface{}
+	}{
+		"page url only":       {page: &model.Page{Url: tests.StringPtr("http://localhost:8000/test/e2e/")}, expected: common.MapStr{"domain": "localhost"}},
+		"with port and query": {page: &model.Page{Url: tests.StringPtr("https://www.example.com:8443/p?q=1#h")}, expected: common.MapStr{"domain": "www.example.com"}},
+		"ipv6 host":           {page: &model.Page{Url: tests.StringPtr("http://[::1]:8080/")}, expected: common.MapStr{"domain": "::1"}},
+		"relative url":        {page: &model.Page{Url: tests.StringPtr("/p/a/t/h")}},
+		"invalid url":         {page: &model.Page{Url: tests.StringPtr("http://%zz")}},
+		"no page url":         {page: &model.Page{Referer: tests.StringPtr("http://localhost")}},
+		"request url domain wins": {
+			page:     &model.Page{Url: tests.StringPtr("http://localhost:8000/")},
+			url:      &model.Url{Domain: tests.StringPtr("www.example.com")},
+			expected: common.MapStr{"domain": "www.example.com"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			event := Event{Page: test.page, Url: test.url}
+			output := event.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			if test.expected == nil {
+				assert.NotContains(t, output[0].Fields, "url")
+			} else {
+				assert.Equal(t, test.expected, output[0].Fields["url"])
+			}
+		})
+	}
+}
+
+func TestEventTransformRefererDomain(t *testing.T) {
+	for name, test := range map[string]struct {
+		referer  *string
+		disabled bool
+		expected interface{}
+	}{
+		"full url":         {referer: tests.StringPtr("https://www.example.com:8443/p?q=1#h"), expected: "www.example.com"},
+		"domain only":      {referer: tests.StringPtr("example.com"), expected: "example.com"},
+		"domain with path": {referer: tests.StringPtr("example.com/p"), expected: "example.com"},
+		"relative url":     {referer: tests.StringPtr("/p/a/t/h")},
+		"invalid url":      {referer: tests.StringPtr("http://%zz")},
+		"empty":            {referer: tests.StringPtr("")},
+		"no referer":       {},
+		"disabled":         {referer: tests.StringPtr("https://www.example.com/"), disabled: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			event := Event{Page: &model.Page{Referer: test.referer}, EmitRefererDomain: !test.disabled}
+			output := event.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			domain, _ := output[0].Fields.GetValue("http.request.referrer.domain")
+			assert.Equal(t, test.expected, domain)
+		})
+	}
+}
+
+func TestTransactionEventServiceOverridesMetadata(t *testing.T) {
+	md := metadata.Metadata{Service: &metadata.Service{
+		Name:        tests.StringPtr("myservice"),
+		Version:     tests.StringPtr("1.0.0"),
+		Environment: tests.StringPtr("production"),
+		Node:        metadata.ServiceNode{Name: tests.StringPtr("node-1")},
+		Framework:   metadata.Framework{Name: tests.StringPtr("gin"), Version: tests.StringPtr("1.6.3")},
+		Language:    metadata.Language{Name: tests.StringPtr("go"), Version: tests.StringPtr("1.14")},
+	}}
+	for name, test := range map[string]struct {
+		service  map[string]interface{}
+		expected common.MapStr
+	}{
+		"no override": {
+			expected: common.MapStr{
+				"name": "myservice", "version": "1.0.0", "environment": "production",
+				"node":      common.MapStr{"name": "node-1"},
+				"framework": common.MapStr{"name": "gin", "version": "1.6.3"},
+				"language":  common.MapStr{"name": "go", "version": "1.14"},
+			},
+		},
+		"version only": {
+			service: map[string]interface{}{"version": "1.1.0"},
+			expected: common.MapStr{
+				"name": "myservice", "version": "1.1.0", "environment": "production",
+				"node":      common.MapStr{"name": "node-1"},
+				"framework": common.MapStr{"name": "gin", "version": "1.6.3"},
+				"language":  common.MapStr{"name": "go", "version": "1.14"},
+			},
+		},
+		"name": {
+			service: map[string]interface{}{"name": "otherservice"},
+			expected: common.MapStr{
+				"name": "otherservice", "version": "1.0.0", "environment": "production",
+				"node":      common.MapStr{"name": "node-1"},
+				"framework": common.MapStr{"name": "gin", "version": "1.6.3"},
+				"language":  common.MapStr{"name": "go", "version": "1.14"},
+			},
+		},
+		"framework name only": {
+			service: map[string]interface{}{"framework": map[string]interface{}{"name": "echo"}},
+			expected: common.MapStr{
+				"name": "myservice", "version": "1.0.0", "environment": "production",
+				"node":      common.MapStr{"name": "node-1"},
+				"framework": common.MapStr{"name": "echo"},
+				"language":  common.MapStr{"name": "go", "version": "1.14"},
+			},
+		},
+		"full": {
+			service: map[string]interface{}{
+				"name": "otherservice", "version": "2.0.0", "environment": "staging",
+				"node":      map[string]interface{}{"configured_name": "node-2"},
+				"framework": map[string]interface{}{"name": "echo", "version": "4.1.0"},
+			},
+			expected: common.MapStr{
+				"name": "otherservice", "version": "2.0.0", "environment": "staging",
+				"node":      common.MapStr{"name": "node-2"},
+				"framework": common.MapStr{"name": "echo", "version": "4.1.0"},
+				"language":  common.MapStr{"name": "go", "version": "1.14"},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.service != nil {
+				input["context"] = map[string]interface{}{"service": test.service}
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input, Metadata: md})
+			require.NoError(t, err)
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			assert.Equal(t, test.expected, output[0].Fields["service"])
+		})
+	}
+}
+
+func TestTransactionEventCloudOverridesMetadata(t *testing.T) {
+	transformable, err := DecodeEvent(model.Input{
+		Raw: map[string]interface{}{
+			"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+			"context": map[string]interface{}{
+				"cloud": map[string]interface{}{
+					"region":   "eu-west-1",
+					"instance": map[string]interface{}{"id": "i-123"},
+				},
+			},
+		},
+		Metadata: metadata.Metadata{Cloud: &metadata.Cloud{
+			Provider:  tests.StringPtr("aws"),
+			Region:    tests.StringPtr("us-east-1"),
+			AccountID: tests.StringPtr("acc-1"),
+		}},
+	})
+	require.NoError(t, err)
+
+	output := transformable.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	assert.Equal(t, common.MapStr{
+		"provider": "aws",
+		"region":   "eu-west-1",
+		"account":  common.MapStr{"id": "acc-1"},
+		"instance": common.MapStr{"id": "i-123"},
+	}, output[0].Fields["cloud"])
+}
+
+func TestTransactionEventDefaultSampledByService(t *testing.T) {
+	defaults := map[string]bool{"batch": false, "web": true}
+	for name, test := range map[string]struct {
+		service  string
+		sampled  interface{}
+		expected bool
+	}{
+		"mapped unsampled":        {service: "batch", expected: false},
+		"mapped sampled":          {service: "web", expected: true},
+		"unmapped":                {service: "other", expected: true},
+		"explicitly sampled":      {service: "batch", sampled: true, expected: true},
+		"explicitly unsampled":    {service: "web", sampled: false, expected: false},
+		"unmapped without a name": {expected: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.sampled != nil {
+				raw["sampled"] = test.sampled
+			}
+			md := metadata.Metadata{Service: &metadata.Service{Name: tests.StringPtr(test.service)}}
+			transformable, err := DecodeEvent(model.Input{Raw: raw, Metadata: md, Config: model.Config{DefaultSampledByService: defaults}})
+			require.NoError(t, err)
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			sampled, err := output[0].Fields.GetValue("transaction.sampled")
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, sampled)
+		})
+	}
+}
+
+func TestTransactionEventUnknownServiceName(t *testing.T) {
+	for name, test := range map[string]struct {
+		metadataName *string
+		eventService map[string]interface{}
+		unknownName  string
+		expected     interface{}
+	}{
+		"metadata name":           {metadataName: tests.StringPtr("myservice"), unknownName: "unknown", expected: "myservice"},
+		"event name":              {eventService: map[string]interface{}{"name": "eventservice"}, unknownName: "unknown", expected: "eventservice"},
+		"absent":                  {unknownName: "unknown", expected: "unknown"},
+		"empty":                   {metadataName: tests.StringPtr(""), unknownName: "unknown", expected: "unknown"},
+		"absent with event agent": {eventService: map[string]interface{}{"agent": map[string]interface{}{"name": "go"}}, unknownName: "unknown", expected: "unknown"},
+		"absent without default":  {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.eventService != nil {
+				raw["context"] = map[string]interface{}{"service": test.eventService}
+			}
+			md := metadata.Metadata{Service: &metadata.Service{Name: test.metadataName}}
+			transformable, err := DecodeEvent(model.Input{Raw: raw, Metadata: md, Config: model.Config{UnknownServiceName: test.unknownName}})
+			require.NoError(t, err)
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			serviceName, _ := output[0].Fields.GetValue("service.name")
+			assert.Equal(t, test.expected, serviceName)
+		})
+	}
+}
+
+func TestTransactionEventAgentOverridesMetadata(t *testing.T) {
+	md := metadata.Metadata{Service: &metadata.Service{
+		Name: tests.StringPtr("myservice"),
+		Agent: metadata.Agent{
+			Name:        tests.StringPtr("java"),
+			Version:     tests.StringPtr("1.10.0"),
+			EphemeralId: tests.StringPtr("metadata-id"),
+		},
+	}}
+	for name, test := range map[string]struct {
+		agent    map[string]interface{}
+		expected common.MapStr
+	}{
+		"metadata only": {
+			expected: common.MapStr{"name": "java", "version": "1.10.0", "ephemeral_id": "metadata-id"},
+		},
+		"ephemeral id override": {
+			agent:    map[string]interface{}{"ephemeral_id": "event-id"},
+			expected: common.MapStr{"name": "java", "version": "1.10.0", "ephemeral_id": "event-id"},
+		},
+		"full override": {
+			agent:    map[string]interface{}{"name": "java", "version": "1.11.0", "ephemeral_id": "event-id"},
+			expected: common.MapStr{"name": "java", "version": "1.11.0", "ephemeral_id": "event-id"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.agent != nil {
+				raw["context"] = map[string]interface{}{"service": map[string]interface{}{"agent": test.agent}}
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: raw, Metadata: md})
+			require.NoError(t, err)
+			if test.agent != nil {
+				event := transformable.(*Event)
+				require.NotNil(t, event.Service)
+				assert.Equal(t, test.agent["ephemeral_id"], *event.Service.Agent.EphemeralId)
+			}
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			assert.Equal(t, test.expected, output[0].Fields["agent"])
+		})
+	}
+}
+
+func TestTransactionEventCoerceBooleanLabels(t *testing.T) {
+	metadataLabels := common.MapStr{"canary": "false", "zone": "a"}
+	raw := map[string]interface{}{
+		"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+		"context": map[string]interface{}{"tags": map[string]interface{}{
+			"cached": "true", "retried": "false", "mixed": "True", "other": "yes", "count": int64(2), "flag": true,
+		}},
+	}
+	for name, test := range map[string]struct {
+		coerce   bool
+		expected common.MapStr
+	}{
+		"coerced": {
+			coerce: true,
+			expected: common.MapStr{
+				"cached": true, "retried": false, "mixed": "True", "other": "yes", "count": int64(2), "flag": true,
+				"canary": false, "zone": "a",
+			},
 		},
-		{
-			Event: Event{
-				Id:        id,
-				Type:      "tx",
-				Duration:  65.98,
-				SpanCount: SpanCount{Dropped: &dropped},
+		"not coerced": {
+			expected: common.MapStr{
+				"cached": "true", "retried": "false", "mixed": "True", "other": "yes", "count": int64(2), "flag": true,
+				"canary": "false", "zone": "a",
 			},
-			Output: common.MapStr{
-				"id":         id,
-				"type":       "tx",
-				"duration":   common.MapStr{"us": 65980},
-				"span_count": common.MapStr{"dropped": 5},
-				"sampled":    true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw:      raw,
+				Metadata: metadata.Metadata{Labels: metadataLabels},
+				Config:   model.Config{CoerceBooleanLabels: test.coerce},
+			})
+			require.NoError(t, err)
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			assert.Equal(t, test.expected, output[0].Fields["labels"])
+			assert.Equal(t, common.MapStr{"canary": "false", "zone": "a"}, metadataLabels)
+		})
+	}
+}
+
+func TestTransactionEventStringifyLabels(t *testing.T) {
+	metadataLabels := common.MapStr{"canary": false, "zone": "a"}
+	raw := map[string]interface{}{
+		"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+		"context": map[string]interface{}{"tags": map[string]interface{}{
+			"name": "checkout", "count": json.Number("2"), "ratio": json.Number("0.5"), "retries": 3.0, "cached": true,
+		}},
+	}
+	for name, test := range map[string]struct {
+		config   model.Config
+		expected common.MapStr
+	}{
+		"preserved": {
+			expected: common.MapStr{
+				"name": "checkout", "count": int64(2), "ratio": common.Float(0.5), "retries": int64(3), "cached": true,
+				"canary": false, "zone": "a",
 			},
-			Msg: "SpanCount only contains `dropped`",
 		},
-		{
-			Event: Event{
-				Id:        id,
-				Name:      &name,
-				Type:      "tx",
-				Result:    &result,
-				Timestamp: time.Now(),
-				Duration:  65.98,
-				Sampled:   &sampled,
-				SpanCount: SpanCount{Started: &startedSpans, Dropped: &dropped},
+		"stringified": {
+			config: model.Config{StringifyLabels: true},
+			expected: common.MapStr{
+				"name": "checkout", "count": "2", "ratio": "0.5", "retries": "3", "cached": "true",
+				"canary": "false", "zone": "a",
 			},
-			Output: common.MapStr{
-				"id":         id,
-				"name":       "mytransaction",
-				"type":       "tx",
-				"result":     "tx result",
-				"duration":   common.MapStr{"us": 65980},
-				"span_count": common.MapStr{"started": 14, "dropped": 5},
-				"sampled":    false,
+		},
+		"stringified after coercion": {
+			config: model.Config{StringifyLabels: true, CoerceBooleanLabels: true},
+			expected: common.MapStr{
+				"name": "checkout", "count": "2", "ratio": "0.5", "retries": "3", "cached": "true",
+				"canary": "false", "zone": "a",
 			},
-			Msg: "Full Event",
 		},
-	}
-
-	tctx := &transform.Context{}
-
-	for idx, test := range tests {
-		output := test.Event.Transform(context.Background(), tctx)
-		assert.Equal(t, test.Output, output[0].Fields["transaction"], fmt.Sprintf("Failed at idx %v; %s", idx, test.Msg))
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw:      raw,
+				Metadata: metadata.Metadata{Labels: metadataLabels},
+				Config:   test.config,
+			})
+			require.NoError(t, err)
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			assert.Equal(t, test.expected, output[0].Fields["labels"])
+			assert.Equal(t, common.MapStr{"canary": false, "zone": "a"}, metadataLabels)
+		})
 	}
 }
 
@@ -380,6 +2343,8 @@ func TestEventsTransformWithMetadata(t *testing.T) {
 	platform := "x64"
 	timestamp := time.Date(2019, 1, 3, 15, 17, 4, 908.596*1e6, time.FixedZone("+0100", 3600))
 	timestampUs := timestamp.UnixNano() / 1000
+	tctx := &transform.Context{Now: func() time.Time { return timestamp }}
+	ingested := common.MapStr{"ingested": "2019-01-03T14:17:04.908596Z"}
 	id, name, ip, userAgent := "123", "jane", "63.23.123.4", "node-js-2.3"
 	user := metadata.User{Id: &id, Name: &name, IP: net.ParseIP(ip), UserAgent: &userAgent}
 	url, referer := "https://localhost", "http://localhost"
@@ -399,13 +2364,14 @@ func TestEventsTransformWithMetadata(t *testing.T) {
 	}
 
 	txValid := Event{Metadata: eventMetadata, Timestamp: timestamp}
-	events := txValid.Transform(context.Background(), &transform.Context{})
+	events := txValid.Transform(context.Background(), tctx)
 	require.Len(t, events, 1)
 	assert.Equal(t, events[0].Fields, common.MapStr{
 		"processor": common.MapStr{
 			"event": "transaction",
 			"name":  "transaction",
 		},
+		"event": ingested,
 		"service": common.MapStr{
 			"name": serviceName,
 			"node": common.MapStr{
@@ -445,7 +2411,7 @@ func TestEventsTransformWithMetadata(t *testing.T) {
 		Message:   &model.Message{QueueName: tests.StringPtr("routeUser")},
 		Service:   &metadata.Service{Version: &serviceVersion},
 	}
-	events = txWithContext.Transform(context.Background(), &transform.Context{})
+	events = txWithContext.Transform(context.Background(), tctx)
 	require.Len(t, events, 1)
 	assert.Equal(t, events[0].Fields, common.MapStr{
 		"user":       common.MapStr{"id": "123", "name": "jane"},
@@ -464,6 +2430,7 @@ func TestEventsTransformWithMetadata(t *testing.T) {
 			"event": "transaction",
 			"name":  "transaction",
 		},
+		"event": ingested,
 		"service": common.MapStr{
 			"name":    serviceName,
 			"version": serviceVersion,
@@ -482,9 +2449,651 @@ func TestEventsTransformWithMetadata(t *testing.T) {
 			"message": common.MapStr{"queue": common.MapStr{"name": "routeUser"}},
 		},
 		"labels": common.MapStr{"a": "b"},
-		"url":    common.MapStr{"original": url},
+		"url":    common.MapStr{"original": url, "domain": "localhost"},
 		"http": common.MapStr{
 			"request":  common.MapStr{"method": "post"},
 			"response": common.MapStr{"finished": false, "headers": common.MapStr{"content-type": []string{"text/html"}}}},
 	})
 }
+
+func TestEventTransformEventCreatedIngested(t *testing.T) {
+	now := time.Date(2019, 1, 3, 15, 17, 5, 123456789, time.UTC)
+	requestTime := time.Date(2019, 1, 3, 15, 17, 6, 0, time.UTC)
+	for name, test := range map[string]struct {
+		input   map[string]interface{}
+		cfg     model.Config
+		created interface{}
+	}{
+		"ingested only": {
+			input: map[string]interface{}{
+				"id": "123", "type": "request", "duration": 1.5, "trace_id": "0123456789abcdef0123456789abcdef",
+				"span_count": map[string]interface{}{"started": 1.0}, "timestamp": json.Number("1546528624908596"),
+			},
+		},
+		"both": {
+			input: map[string]interface{}{
+				"id": "123", "type": "request", "duration": 1.5, "trace_id": "0123456789abcdef0123456789abcdef",
+				"span_count": map[string]interface{}{"started": 1.0}, "timestamp": json.Number("1546528624908596"),
+			},
+			cfg:     model.Config{EmitEventCreated: true},
+			created: "2019-01-03T15:17:04.908596Z",
+		},
+		"no timestamp": {
+			input: map[string]interface{}{
+				"id": "123", "type": "request", "duration": 1.5, "trace_id": "0123456789abcdef0123456789abcdef",
+				"span_count": map[string]interface{}{"started": 1.0},
+			},
+			cfg:     model.Config{EmitEventCreated: true},
+			created: "2019-01-03T15:17:05.123456789Z",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{Raw: test.input, RequestTime: requestTime, Config: test.cfg})
+			require.NoError(t, err)
+			tctx := &transform.Context{Now: func() time.Time { return now }}
+			output := transformable.Transform(context.Background(), tctx)
+			require.Len(t, output, 1)
+
+			created, _ := output[0].Fields.GetValue("event.created")
+			assert.Equal(t, test.created, created)
+			ingested, _ := output[0].Fields.GetValue("event.ingested")
+			assert.Equal(t, "2019-01-03T15:17:05.123456789Z", ingested)
+		})
+	}
+}
+
+func TestEventTransformEventIngestedNoTimestamp(t *testing.T) {
+	tctx := &transform.Context{}
+	before := time.Now()
+	output := (&Event{}).Transform(context.Background(), tctx)
+	require.Len(t, output, 1)
+
+	ingested, err := output[0].Fields.GetValue("event.ingested")
+	require.NoError(t, err)
+	require.IsType(t, "", ingested)
+	parsed, err := time.Parse(time.RFC3339Nano, ingested.(string))
+	require.NoError(t, err)
+	assert.False(t, parsed.Before(before.Truncate(time.Nanosecond)))
+}
+
+func TestEventTransformSourceNAT(t *testing.T) {
+	for name, test := range map[string]struct {
+		emit     bool
+		socketIP string
+		source   common.MapStr
+	}{
+		"proxied": {
+			emit: true, socketIP: "10.0.0.2",
+			source: common.MapStr{"ip": "198.51.100.7", "nat": common.MapStr{"ip": "10.0.0.2"}},
+		},
+		"same ip": {
+			emit: true, socketIP: "198.51.100.7",
+			source: common.MapStr{"ip": "198.51.100.7"},
+		},
+		"proxied, not emitted": {
+			socketIP: "10.0.0.2",
+			source:   common.MapStr{"ip": "198.51.100.7"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transformable, err := DecodeEvent(model.Input{
+				Raw: map[string]interface{}{
+					"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+					"context": map[string]interface{}{
+						"request": map[string]interface{}{
+							"method":  "GET",
+							"headers": map[string]interface{}{"X-Forwarded-For": "198.51.100.7"},
+							"socket":  map[string]interface{}{"remote_address": test.socketIP},
+						},
+					},
+				},
+				Config: model.Config{EmitSourceNAT: test.emit},
+			})
+			require.NoError(t, err)
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			assert.Equal(t, test.source, output[0].Fields["source"])
+			assert.Equal(t, common.MapStr{"ip": "198.51.100.7"}, output[0].Fields["client"])
+		})
+	}
+}
+
+type fakeGeoResolver map[string]*model.Geo
+
+func (r fakeGeoResolver) Resolve(ip net.IP) *model.Geo {
+	return r[ip.String()]
+}
+
+func TestEventTransformClientGeo(t *testing.T) {
+	resolver := fakeGeoResolver{
+		"198.51.100.7": {
+			CountryISOCode: "CA",
+			CityName:       "Montreal",
+			Location:       &model.GeoLocation{Lat: 45.505918, Lon: -73.61483},
+		},
+		"203.0.113.9": {CountryISOCode: "NZ"},
+	}
+	for name, test := range map[string]struct {
+		resolver model.GeoResolver
+		clientIP string
+		client   common.MapStr
+	}{
+		"resolved": {
+			resolver: resolver,
+			clientIP: "198.51.100.7",
+			client: common.MapStr{"ip": "198.51.100.7", "geo": common.MapStr{
+				"country_iso_code": "CA",
+				"city_name":        "Montreal",
+				"location":         common.MapStr{"lat": common.Float(45.505918), "lon": common.Float(-73.61483)},
+			}},
+		},
+		"partially resolved": {
+			resolver: resolver,
+			clientIP: "203.0.113.9",
+			client:   common.MapStr{"ip": "203.0.113.9", "geo": common.MapStr{"country_iso_code": "NZ"}},
+		},
+		"unresolved": {
+			resolver: resolver,
+			clientIP: "192.0.2.1",
+			client:   common.MapStr{"ip": "192.0.2.1"},
+		},
+		"no resolver": {
+			clientIP: "198.51.100.7",
+			client:   common.MapStr{"ip": "198.51.100.7"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			event := Event{Client: &model.Client{IP: net.ParseIP(test.clientIP)}, GeoResolver: test.resolver}
+			output := event.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			assert.Equal(t, test.client, output[0].Fields["client"])
+			assert.Equal(t, common.MapStr{"ip": test.clientIP}, output[0].Fields["source"])
+			assert.Nil(t, event.Client.Geo)
+		})
+	}
+}
+
+func TestTransactionEventDecodeGeoResolver(t *testing.T) {
+	resolver := fakeGeoResolver{"198.51.100.7": {CountryISOCode: "CA"}}
+	transformable, err := DecodeEvent(model.Input{
+		Raw: map[string]interface{}{
+			"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef",
+			"context": map[string]interface{}{
+				"request": map[string]interface{}{
+					"method": "GET",
+					"socket": map[string]interface{}{"remote_address": "198.51.100.7"},
+				},
+			},
+		},
+		Config: model.Config{GeoResolver: resolver},
+	})
+	require.NoError(t, err)
+
+	output := transformable.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	assert.Equal(t, common.MapStr{"ip": "198.51.100.7", "geo": common.MapStr{"country_iso_code": "CA"}}, output[0].Fields["client"])
+}
+
+func TestTransactionEventDecodeUser(t *testing.T) {
+	for name, test := range map[string]struct {
+		user     map[string]interface{}
+		expected interface{}
+	}{
+		"all": {
+			user:     map[string]interface{}{"id": "123", "username": "jdoe", "email": "jdoe@example.com", "domain": "CORP"},
+			expected: common.MapStr{"id": "123", "name": "jdoe", "email": "jdoe@example.com", "domain": "CORP"},
+		},
+		"email and domain": {
+			user:     map[string]interface{}{"email": "jdoe@example.com", "domain": "CORP"},
+			expected: common.MapStr{"email": "jdoe@example.com", "domain": "CORP"},
+		},
+		"absent": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			if test.user != nil {
+				input["context"] = map[string]interface{}{"user": test.user}
+			}
+			transformable, err := DecodeEvent(model.Input{Raw: input})
+			require.NoError(t, err)
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			user, _ := output[0].Fields.GetValue("user")
+			assert.Equal(t, test.expected, user)
+		})
+	}
+}
+
+func TestEventTransformClientGeoLookup(t *testing.T) {
+	lookup := func(ip net.IP) (string, string) {
+		switch ip.String() {
+		case "198.51.100.7":
+			return "CA", "Montreal"
+		case "203.0.113.9":
+			return "NZ", ""
+		}
+		return "", ""
+	}
+	for name, test := range map[string]struct {
+		lookup   func(net.IP) (string, string)
+		clientIP string
+		client   common.MapStr
+	}{
+		"found": {
+			lookup:   lookup,
+			clientIP: "198.51.100.7",
+			client:   common.MapStr{"ip": "198.51.100.7", "geo": common.MapStr{"country_iso_code": "CA", "city_name": "Montreal"}},
+		},
+		"country only": {
+			lookup:   lookup,
+			clientIP: "203.0.113.9",
+			client:   common.MapStr{"ip": "203.0.113.9", "geo": common.MapStr{"country_iso_code": "NZ"}},
+		},
+		"not found": {
+			lookup:   lookup,
+			clientIP: "192.0.2.1",
+			client:   common.MapStr{"ip": "192.0.2.1"},
+		},
+		"nil lookup": {
+			clientIP: "198.51.100.7",
+			client:   common.MapStr{"ip": "198.51.100.7"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			event := Event{Client: &model.Client{IP: net.ParseIP(test.clientIP)}}
+			output := event.Transform(context.Background(), &transform.Context{GeoLookup: test.lookup})
+			require.Len(t, output, 1)
+			assert.Equal(t, test.client, output[0].Fields["client"])
+		})
+	}
+}
+
+func TestEventTransformClientGeoPrecedence(t *testing.T) {
+	resolver := fakeGeoResolver{"198.51.100.7": {CountryISOCode: "CA"}}
+	var looked []string
+	lookup := func(ip net.IP) (string, string) {
+		looked = append(looked, ip.String())
+		return "NZ", "Wellington"
+	}
+	tctx := &transform.Context{GeoLookup: lookup}
+	for clientIP, expected := range map[string]common.MapStr{
+		"198.51.100.7": {"ip": "198.51.100.7", "geo": common.MapStr{"country_iso_code": "CA"}},
+		"192.0.2.1":    {"ip": "192.0.2.1"},
+	} {
+		event := Event{Client: &model.Client{IP: net.ParseIP(clientIP)}, GeoResolver: resolver}
+		output := event.Transform(context.Background(), tctx)
+		require.Len(t, output, 1)
+		assert.Equal(t, expected, output[0].Fields["client"])
+	}
+	assert.Empty(t, looked, "GeoLookup must not be used when GeoResolver is set")
+}
+
+func TestEventTransformFAAS(t *testing.T) {
+	event := Event{FAAS: &FAAS{
+		ID:          tests.StringPtr("my-function"),
+		Coldstart:   new(bool),
+		TriggerType: tests.StringPtr("http"),
+	}}
+	output := event.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	assert.Equal(t, common.MapStr{
+		"id":        "my-function",
+		"coldstart": false,
+		"trigger":   common.MapStr{"type": "http"},
+	}, output[0].Fields["faas"])
+
+	output = (&Event{}).Transform(context.Background(), &transform.Context{})
+	assert.NotContains(t, output[0].Fields, "faas")
+}
+
+func TestEventTransformServiceNodeName(t *testing.T) {
+	hostname := "host-1"
+	for name, test := range map[string]struct {
+		raw      map[string]interface{}
+		expected common.MapStr
+	}{
+		"from hostname": {
+			expected: common.MapStr{"name": hostname},
+		},
+		"explicit node name": {
+			raw: map[string]interface{}{"context": map[string]interface{}{
+				"service": map[string]interface{}{"node": map[string]interface{}{"configured_name": "node-1"}},
+			}},
+			expected: common.MapStr{"name": "node-1"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]interface{}{"id": "123", "type": "tx", "duration": 1.0, "trace_id": "0123456789abcdef0123456789abcdef"}
+			for k, v := range test.raw {
+				raw[k] = v
+			}
+			transformable, err := DecodeEvent(model.Input{
+				Raw: raw,
+				Metadata: metadata.Metadata{
+					Service: &metadata.Service{Name: tests.StringPtr("myservice")},
+					System:  &metadata.System{DetectedHostname: &hostname},
+				},
+			})
+			require.NoError(t, err)
+
+			output := transformable.Transform(context.Background(), &transform.Context{})
+			require.Len(t, output, 1)
+			service := output[0].Fields["service"].(common.MapStr)
+			assert.Equal(t, test.expected, service["node"])
+			assert.Equal(t, hostname, output[0].Fields["host"].(common.MapStr)["hostname"])
+		})
+	}
+}
+
+func TestEventOutcomeMetrics(t *testing.T) {
+	name, timestamp := "GET /", time.Date(2019, 1, 3, 15, 17, 4, 0, time.UTC)
+	for outcome, expected := range map[string][]*metricset.Sample{
+		"success": {
+			{Name: "event.success.count", Value: 1, Type: tests.StringPtr("counter")},
+			{Name: "event.failure.count", Value: 0, Type: tests.StringPtr("counter")},
+		},
+		"failure": {
+			{Name: "event.success.count", Value: 0, Type: tests.StringPtr("counter")},
+			{Name: "event.failure.count", Value: 1, Type: tests.StringPtr("counter")},
+		},
+		"unknown": nil,
+	} {
+		t.Run(outcome, func(t *testing.T) {
+			event := &Event{Type: "request", Name: &name, Outcome: tests.StringPtr(outcome), Timestamp: timestamp}
+			metricsets := event.OutcomeMetrics()
+			if expected == nil {
+				assert.Empty(t, metricsets)
+				return
+			}
+			require.Len(t, metricsets, 1)
+			assert.Equal(t, expected, metricsets[0].Samples)
+			assert.Equal(t, &metricset.Transaction{Name: &name, Type: tests.StringPtr("request")}, metricsets[0].Transaction)
+			assert.Equal(t, timestamp, metricsets[0].Timestamp)
+		})
+	}
+	assert.Empty(t, (&Event{Type: "request"}).OutcomeMetrics())
+}
+
+func TestAggregateDurations(t *testing.T) {
+	bounds := []float64{10, 100, 1000}
+	weighted := func(duration, sampleRate float64) *Event {
+		return &Event{Duration: duration, SampleRate: &sampleRate, RepresentativeCount: 1 / sampleRate}
+	}
+	for name, test := range map[string]struct {
+		events []*Event
+		counts []int64
+		values []float64
+	}{
+		"empty": {
+			counts: []int64{0, 0, 0, 0},
+			values: []float64{10, 100, 1000, 0},
+		},
+		"unweighted": {
+			events: []*Event{{Duration: 0}, {Duration: 10}, {Duration: 10.5}, {Duration: 999}, {Duration: 1500}, {Duration: 1200}},
+			counts: []int64{2, 1, 1, 2},
+			values: []float64{10, 100, 1000, 1500},
+		},
+		"weighted": {
+			events: []*Event{weighted(5, 0.5), weighted(50, 0.1), {Duration: 50}, weighted(5000, 0.25)},
+			counts: []int64{2, 11, 0, 4},
+			values: []float64{10, 100, 1000, 5000},
+		},
+		"fractional weights": {
+			events: []*Event{weighted(1, 0.3), weighted(2, 0.3), weighted(3, 0.3)},
+			counts: []int64{10, 0, 0, 0},
+			values: []float64{10, 100, 1000, 0},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			counts, values := AggregateDurations(test.events, bounds)
+			assert.Equal(t, test.counts, counts)
+			assert.Equal(t, test.values, values)
+		})
+	}
+}
+
+func TestEventEstimatedSize(t *testing.T) {
+	event := &Event{
+		Id:       "0123456789abcdef",
+		TraceId:  "0123456789abcdef0123456789abcdef",
+		Type:     "request",
+		Name:     tests.StringPtr("GET /"),
+		Duration: 1.5,
+		SpanCount: SpanCount{
+			Started: tests.IntPtr(3),
+		},
+	}
+	// id + trace_id + type + name + timestamp + duration + span_count.started
+	expected := 16 + 32 + 7 + 5 + 3*8
+	assert.Equal(t, expected, event.EstimatedSize())
+
+	event.Labels = &model.Labels{"zone": "a"}
+	event.Custom = &model.Custom{"k": json.Number("1")}
+	event.Marks = common.MapStr{"agent": common.MapStr{"ready": 1.5}}
+	event.Http = &model.Http{Request: &model.Req{Method: "GET", Headers: http.Header{"Accept": {"*/*"}}}}
+	event.Links = []SpanLink{{TraceId: "0123456789abcdef0123456789abcdef", SpanId: "0123456789abcdef"}}
+	event.Metadata = metadata.Metadata{Service: &metadata.Service{Name: tests.StringPtr("svc")}}
+	// + labels + custom + marks + method and headers + link + service name
+	expected += 5 + 1 + 8 + 5 + 5 + 8 + 3 + 6 + 3 + 32 + 16 + 3
+	assert.Equal(t, expected, event.EstimatedSize())
+
+	// the estimate is not published with the event
+	output := event.Transform(context.Background(), &transform.Context{})
+	require.Len(t, output, 1)
+	assert.Nil(t, output[0].Meta)
+}
+
+func TestEventTransformMapStrPool(t *testing.T) {
+	events := decodeTestTransactions(t)
+	pool := &transform.MapStrPool{}
+	now := time.Now()
+	nowFunc := func() time.Time { return now }
+	for i := 0; i < 2; i++ {
+		var pooled []beat.Event
+		for _, event := range events {
+			expected := event.Transform(context.Background(), &transform.Context{Now: nowFunc})
+			output := event.Transform(context.Background(), &transform.Context{Now: nowFunc, MapStrPool: pool})
+			assert.Equal(t, expected, output)
+			pooled = append(pooled, output...)
+		}
+		pool.Reset()
+		for _, event := range pooled {
+			assert.Empty(t, event.Fields)
+		}
+	}
+}
+
+func decodeTestTransactions(tb testing.TB) []transform.Transformable {
+	data, err := loader.LoadDataAsBytes("../testdata/intake-v2/transactions.ndjson")
+	require.NoError(tb, err)
+	meta := metadata.Metadata{Service: &metadata.Service{Name: tests.StringPtr("myservice")}}
+
+	var events []transform.Transformable
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var event map[string]interface{}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		require.NoError(tb, decoder.NewJSONDecoder(bytes.NewReader(line)).Decode(&event))
+		if raw, ok := event["transaction"]; ok {
+			decoded, err := DecodeEvent(model.Input{Raw: raw, Metadata: meta, Config: model.Config{Experimental: true}})
+			require.NoError(tb, err)
+			events = append(events, decoded)
+		}
+	}
+	require.NotEmpty(tb, events)
+	return events
+}
+
+func BenchmarkEventTransform(b *testing.B) {
+	events := decodeTestTransactions(b)
+	b.Run("fresh", func(b *testing.B) {
+		tctx := &transform.Context{}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, event := range events {
+				event.Transform(context.Background(), tctx)
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		tctx := &transform.Context{MapStrPool: &transform.MapStrPool{}}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, event := range events {
+				event.Transform(context.Background(), tctx)
+			}
+			tctx.MapStrPool.Reset()
+		}
+	})
+}
+
+func TestTransformAll(t *testing.T) {
+	now := time.Now()
+	tctx := &transform.Context{Now: func() time.Time { return now }}
+	var events []*Event
+	var expected []beat.Event
+	for _, transformable := range decodeTestTransactions(t) {
+		events = append(events, transformable.(*Event))
+		expected = append(expected, transformable.Transform(context.Background(), tctx)...)
+	}
+	output := TransformAll(context.Background(), tctx, events)
+	assert.Equal(t, expected, output)
+	assert.Empty(t, TransformAll(context.Background(), &transform.Context{}, nil))
+}
+
+func BenchmarkTransformAll(b *testing.B) {
+	var events []*Event
+	for _, transformable := range decodeTestTransactions(b) {
+		events = append(events, transformable.(*Event))
+	}
+	for len(events) < 1000 {
+		events = append(events, events...)
+	}
+	tctx := &transform.Context{}
+	b.Run("per-event", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var out []beat.Event
+			for _, event := range events {
+				out = append(out, event.Transform(context.Background(), tctx)...)
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			TransformAll(context.Background(), tctx, events)
+		}
+	})
+}
+
+func BenchmarkEventEstimatedSize(b *testing.B) {
+	event := &Event{Id: "0123456789abcdef", TraceId: "0123456789abcdef0123456789abcdef", Type: "request"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		event.EstimatedSize()
+	}
+}
+
+func BenchmarkEventMarshal(b *testing.B) {
+	event := &Event{Id: "0123456789abcdef", TraceId: "0123456789abcdef0123456789abcdef", Type: "request"}
+	fields := event.Transform(context.Background(), &transform.Context{})[0].Fields
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(fields); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecodeEventFromReader(t *testing.T) {
+	data, err := loader.LoadDataAsBytes("../testdata/intake-v2/transactions.ndjson")
+	require.NoError(t, err)
+	meta := metadata.Metadata{Service: &metadata.Service{Name: tests.StringPtr("myservice")}}
+
+	for _, cfg := range []model.Config{{}, {Experimental: true}} {
+		var decoded int
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var event map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(line, &event))
+			raw, ok := event["transaction"]
+			if !ok {
+				continue
+			}
+			decoded++
+			assertDecodeEventFromReader(t, raw, cfg, meta)
+		}
+		assert.NotZero(t, decoded)
+	}
+}
+
+func TestDecodeEventFromReaderSkipped(t *testing.T) {
+	input := `{"id":"85925e55b43f4342","type":"request","duration":1,"trace_id":"0acd456789abcdef0123456789abcdef",` +
+		`"span_count":{"started":1},"context":{"experimental":{"a":[1,{"b":2}]},"custom":{"c":3}},` +
+		`"profiler_stack_trace_ids":["a","b","c"]}`
+	for name, test := range map[string]struct {
+		cfg model.Config
+		ids []string
+	}{
+		"defaults":     {ids: []string{"a", "b", "c"}},
+		"experimental": {cfg: model.Config{Experimental: true}, ids: []string{"a", "b", "c"}},
+		"max":          {cfg: model.Config{MaxStackTraceIDs: 2}, ids: []string{"a", "b"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			event := assertDecodeEventFromReader(t, []byte(input), test.cfg, metadata.Metadata{})
+			if test.cfg.Experimental {
+				assert.NotNil(t, event.Experimental)
+			} else {
+				assert.Nil(t, event.Experimental)
+			}
+			assert.Equal(t, test.ids, event.ProfilerStackTraceIDs)
+		})
+	}
+}
+
+func TestDecodeEventFromReaderInvalid(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		err   error
+	}{
+		{input: `null`, err: errMissingInput},
+		{input: `[]`, err: errInvalidType},
+		{input: `{"id":`},
+		{input: `{"context":`},
+		{input: `{"context":"x","profiler_stack_trace_ids":1}`},
+		{input: `{"profiler_stack_trace_ids":[1`},
+	} {
+		_, err := DecodeEventFromReader(strings.NewReader(test.input), model.Config{}, metadata.Metadata{})
+		if test.err != nil {
+			assert.Equal(t, test.err, err)
+		} else {
+			assert.Error(t, err, test.input)
+		}
+	}
+}
+
+// assertDecodeEventFromReader decodes input with DecodeEventFromReader,
+// and cross-checks the result with the map-based DecodeEvent.
+func assertDecodeEventFromReader(t *testing.T, input []byte, cfg model.Config, meta metadata.Metadata) *Event {
+	fromReader, err := DecodeEventFromReader(bytes.NewReader(input), cfg, meta)
+	require.NoError(t, err)
+
+	raw, err := decoder.DecodeJSONData(bytes.NewReader(input))
+	require.NoError(t, err)
+	fromMap, err := DecodeEvent(model.Input{
+		Raw:         raw,
+		RequestTime: fromReader.(*Event).Timestamp,
+		Metadata:    meta,
+		Config:      cfg,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, fromMap, fromReader)
+	return fromReader.(*Event)
+}
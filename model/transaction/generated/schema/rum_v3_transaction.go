@@ -204,7 +204,8 @@ const RUMV3Schema = `{
             ]
         },
         "g": {
-                "$id": "doc/spec/tags.json",
+            "anyOf": [
+                {     "$id": "doc/spec/tags.json",
     "title": "Tags",
     "type": ["object", "null"],
     "description": "A flat mapping of user-defined tags with string, boolean or number values.",
@@ -214,7 +215,25 @@ const RUMV3Schema = `{
             "maxLength": 1024
         }
     },
-    "additionalProperties": false
+    "additionalProperties": false },
+                {     "$id": "doc/spec/tags_array.json",
+    "title": "Tags Array",
+    "type": ["array"],
+    "description": "User-defined tags sent as an array of key/value objects, e.g. [{\"key\": \"a\", \"value\": \"b\"}]. Only accepted if the server is configured to accept array tags. Elements missing a key or value, or with a key containing any of \".\", \"*\" and \"\\\"\", are skipped.",
+    "items": {
+        "type": "object",
+        "properties": {
+            "key": {
+                "type": "string",
+                "maxLength": 1024
+            },
+            "value": {
+                "type": ["string", "boolean", "number", "null"],
+                "maxLength": 1024
+            }
+        }
+    } }
+            ]
         },
         "u": {
                 "$id": "docs/spec/rum_v3_user.json",
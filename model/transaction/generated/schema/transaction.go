@@ -22,14 +22,15 @@ const ModelSchema = `{
     "type": "object",
     "description": "An event corresponding to an incoming request or similar task occurring in a monitored service",
     "allOf": [
-        {     "$id": "doc/spec/timestamp_epoch.json",
-    "title": "Timestamp Epoch",
-    "description": "Object with 'timestamp' property.",
+        {     "$id": "doc/spec/timestamp_epoch_or_offset.json",
+    "title": "Timestamp Epoch or Offset",
+    "description": "Object with 'timestamp' property, which may be relative to the request time.",
     "type": ["object"],
-    "properties": {  
+    "properties": {
         "timestamp": {
-            "description": "Recorded time of the event, UTC based and formatted as microseconds since Unix epoch",
-            "type": ["integer", "null"]
+            "description": "Recorded time of the event, UTC based and formatted as microseconds since Unix epoch, or as a signed offset in microseconds relative to the time the request was received, e.g. \"-1500\"",
+            "type": ["integer", "string", "null"],
+            "pattern": "^[+-][0-9]+$"
         }
     } },
         {     "$id": "docs/spec/transaction_name.json",
@@ -85,6 +86,48 @@ const ModelSchema = `{
                     },
                     "required": ["started"]
                 },
+                "dropped_spans_stats": {
+                    "type": ["array", "null"],
+                    "description": "Statistics of the spans dropped by the agent, aggregated per destination service resource and outcome.",
+                    "items": {
+                        "type": "object",
+                        "properties": {
+                            "destination_service_resource": {
+                                "type": "string",
+                                "description": "Destination service resource of the dropped spans, e.g. mysql.",
+                                "maxLength": 1024
+                            },
+                            "outcome": {
+                                "type": "string",
+                                "description": "Outcome of the dropped spans.",
+                                "enum": ["success", "failure", "unknown"]
+                            },
+                            "duration": {
+                                "type": "object",
+                                "properties": {
+                                    "count": {
+                                        "type": "integer",
+                                        "description": "Number of dropped spans.",
+                                        "minimum": 0
+                                    },
+                                    "sum": {
+                                        "type": "object",
+                                        "properties": {
+                                            "us": {
+                                                "type": "integer",
+                                                "description": "Sum of the durations of the dropped spans, in microseconds.",
+                                                "minimum": 0
+                                            }
+                                        },
+                                        "required": ["us"]
+                                    }
+                                },
+                                "required": ["count", "sum"]
+                            }
+                        },
+                        "required": ["destination_service_resource", "outcome", "duration"]
+                    }
+                },
                 "context": {
                         "$id": "doc/spec/context.json",
     "title": "Context",
@@ -258,7 +301,8 @@ const ModelSchema = `{
     "required": ["url", "method"]
         },
         "tags": {
-                "$id": "doc/spec/tags.json",
+            "anyOf": [
+                {     "$id": "doc/spec/tags.json",
     "title": "Tags",
     "type": ["object", "null"],
     "description": "A flat mapping of user-defined tags with string, boolean or number values.",
@@ -268,7 +312,25 @@ const ModelSchema = `{
             "maxLength": 1024
         }
     },
-    "additionalProperties": false
+    "additionalProperties": false },
+                {     "$id": "doc/spec/tags_array.json",
+    "title": "Tags Array",
+    "type": ["array"],
+    "description": "User-defined tags sent as an array of key/value objects, e.g. [{\"key\": \"a\", \"value\": \"b\"}]. Only accepted if the server is configured to accept array tags. Elements missing a key or value, or with a key containing any of \".\", \"*\" and \"\\\"\", are skipped.",
+    "items": {
+        "type": "object",
+        "properties": {
+            "key": {
+                "type": "string",
+                "maxLength": 1024
+            },
+            "value": {
+                "type": ["string", "boolean", "number", "null"],
+                "maxLength": 1024
+            }
+        }
+    } }
+            ]
         },
         "user": {
             "description": "Describes the correlated user for this event. If user data are provided here, all user related information from metadata is ignored, otherwise the metadata's user information will be stored with the event.",
@@ -290,6 +352,11 @@ const ModelSchema = `{
             "description": "The username of the logged in user",
             "type": ["string", "null"],
             "maxLength": 1024
+        },
+        "domain": {
+            "description": "Domain of the logged in user, e.g. a Windows or Active Directory domain",
+            "type": ["string", "null"],
+            "maxLength": 1024
         }
     }
         },
@@ -411,7 +478,9 @@ const ModelSchema = `{
     "type": ["object", "null"],
     "properties": {
         "queue": {
-            "type": ["object", "null"],
+            "description": "The message queue, either as an object or, for agents sending it flat, as the queue name.",
+            "type": ["object", "string", "null"],
+            "maxLength": 1024,
             "properties": {
                 "name": {
                     "description": "Name of the message queue where the message is received.",
@@ -420,6 +489,16 @@ const ModelSchema = `{
                 }
             }
         },
+        "routing_key": {
+            "description": "Routing key of the message, for brokers that route messages by key, e.g. RabbitMQ.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "exchange": {
+            "description": "Name of the exchange the message is published to, e.g. a RabbitMQ exchange.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
         "age": {
             "type": ["object", "null"],
             "properties": {
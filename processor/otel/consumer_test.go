@@ -66,7 +66,7 @@ func TestConsumer_ConsumeTraceData(t *testing.T) {
 				for _, transformable := range p.Transformables {
 					events = append(events, transformable.Transform(ctx, p.Tcontext)...)
 				}
-				assert.NoError(t, approvals.ApproveEvents(events, file("consume_"+tc.name)))
+				assert.NoError(t, approvals.ApproveEvents(events, file("consume_"+tc.name), "ingested"))
 				return nil
 			}
 			consumer := Consumer{Reporter: reporter}
@@ -250,6 +250,20 @@ func TestConsumer_Transaction(t *testing.T) {
 	}
 }
 
+func TestParseTransactionHTTPMethodOnly(t *testing.T) {
+	var event transaction.Event
+	parseTransaction(&tracepb.Span{
+		Attributes: &tracepb.Span_Attributes{AttributeMap: map[string]*tracepb.AttributeValue{
+			"http.method": testAttributeStringValue("get"),
+		}},
+	}, "host-abc", &event)
+	require.NotNil(t, event.Http)
+	require.NotNil(t, event.Http.Request)
+	assert.Equal(t, "get", event.Http.Request.Method)
+	assert.Equal(t, "request", event.Type)
+	assert.Nil(t, event.Http.Response)
+}
+
 func TestConsumer_Span(t *testing.T) {
 	for _, tc := range []struct {
 		name string
@@ -58,7 +58,7 @@ func errorPayloadAttrsNotInFields() *tests.Set {
 func errorFieldsNotInPayloadAttrs() *tests.Set {
 	return tests.NewSet(
 		"view errors", "error id icon",
-		"host.ip", "transaction.name", "source.ip",
+		"host.ip", "host.os.full", "transaction.name", "source.ip",
 		tests.Group("observer"),
 		tests.Group("user"),
 		tests.Group("client"),
@@ -69,6 +69,8 @@ func errorFieldsNotInPayloadAttrs() *tests.Set {
 		tests.Group("transaction.self_time"),
 		tests.Group("transaction.breakdown"),
 		tests.Group("transaction.duration"),
+		tests.Group("service.target"),
+		tests.Group("service.origin"),
 		"experimental",
 	)
 }
@@ -160,7 +162,11 @@ func TestErrorPayloadAttrsMatchJsonSchema(t *testing.T) {
 	errorProcSetup().PayloadAttrsMatchJsonSchema(t,
 		errorPayloadAttrsNotInJsonSchema(),
 		tests.NewSet(
+			// only in array tags
+			"error.context.tags.key",
+			"error.context.tags.value",
 			"error.context.user.email",
+			"error.context.user.domain",
 			"error.context.experimental",
 			"error.exception.parent", // it will never be present in the top (first) exception
 			tests.Group("error.context.message"),
@@ -193,7 +199,10 @@ func TestPayloadDataForError(t *testing.T) {
 	//// * multiple allowed data types
 	//// * regex pattern, time formats
 	//// * length restrictions, other than keyword length restrictions
-	errorProcSetup().DataValidation(t,
+	setup := errorProcSetup()
+	// decode the array tags that validate against the schema
+	setup.Proc.(*intakeTestProcessor).Mconfig.AcceptArrayTags = true
+	setup.DataValidation(t,
 		[]tests.SchemaTestData{
 			{Key: "error",
 				Invalid: []tests.Invalid{{Msg: `/type`, Values: val{false}}}},
@@ -248,11 +257,14 @@ func TestPayloadDataForError(t *testing.T) {
 			{Key: "error.context.request.cookies", Valid: val{obj{}},
 				Invalid: []tests.Invalid{{Msg: `/context/properties/request/properties/cookies/type`, Values: val{102, "a"}}}},
 			{Key: "error.context.tags",
-				Valid: val{obj{tests.Str1024Special: tests.Str1024Special}, obj{tests.Str1024: 123.45}, obj{tests.Str1024: true}},
+				Valid: val{obj{tests.Str1024Special: tests.Str1024Special}, obj{tests.Str1024: 123.45}, obj{tests.Str1024: true},
+					[]interface{}{obj{"key": "a", "value": "b"}, obj{"key": tests.Str1024, "value": 123.45}, obj{"key": "c", "value": nil}},
+					[]interface{}{obj{"key": "a"}}, []interface{}{obj{"key": "a.b", "value": "c"}}},
 				Invalid: []tests.Invalid{
-					{Msg: `context/properties/tags/type`, Values: val{"tags"}},
-					{Msg: `context/properties/tags/patternproperties`, Values: val{obj{"invalid": tests.Str1025}, obj{tests.Str1024: obj{}}}},
-					{Msg: `context/properties/tags/additionalproperties`, Values: val{obj{"invali*d": "hello"}, obj{"invali\"d": "hello"}, obj{"invali.d": "hello"}}}}},
+					{Msg: `context/properties/tags/anyof/0/type`, Values: val{"tags"}},
+					{Msg: `context/properties/tags/anyof/0/patternproperties`, Values: val{obj{"invalid": tests.Str1025}, obj{tests.Str1024: obj{}}}},
+					{Msg: `context/properties/tags/anyof/0/additionalproperties`, Values: val{obj{"invali*d": "hello"}, obj{"invali\"d": "hello"}, obj{"invali.d": "hello"}}},
+					{Msg: `context/properties/tags/anyof/1/items/properties/key/maxlength`, Values: val{[]interface{}{obj{"key": tests.Str1025, "value": "c"}}}}}},
 			{Key: "error.context.user.id", Valid: val{123, tests.Str1024Special},
 				Invalid: []tests.Invalid{
 					{Msg: `context/properties/user/properties/id/type`, Values: val{obj{}}},
@@ -107,6 +107,7 @@ func TestMetadataPayloadAttrsMatchFields(t *testing.T) {
 		{Template: "system.kubernetes.pod", Mapping: ""},                   // delete system.kubernetes.pod
 		{Template: "system.kubernetes.", Mapping: "kubernetes."},           // move system.kubernetes.*
 		{Template: "system.kubernetes", Mapping: ""},                       // delete system.kubernetes
+		{Template: "system.platform_version", Mapping: "host.os.full"},
 		{Template: "system.platform", Mapping: "host.os.platform"},
 		{Template: "system.configured_hostname", Mapping: "host.name"},
 		{Template: "system.detected_hostname", Mapping: "host.hostname"},
@@ -116,6 +117,7 @@ func TestMetadataPayloadAttrsMatchFields(t *testing.T) {
 		{Template: "process.argv", Mapping: "process.args"},
 		{Template: "labels.*", Mapping: "labels"},
 		{Template: "service.node.configured_name", Mapping: "service.node.name"},
+		{Template: "cloud.*", Mapping: ""}, // cloud.* is defined by the libbeat ECS fields
 	}
 	setup.EventFieldsMappedToTemplateFields(t, eventFields, mappingFields)
 }
@@ -124,7 +126,7 @@ func TestMetadataPayloadMatchJsonSchema(t *testing.T) {
 	metadataProcSetup().AttrsMatchJsonSchema(t,
 		getMetadataEventAttrs(t, ""),
 		tests.NewSet(tests.Group("labels")),
-		nil,
+		tests.NewSet("user.domain"),
 	)
 }
 
@@ -143,11 +145,14 @@ func TestKeywordLimitationOnMetadataAttrs(t *testing.T) {
 			tests.Group("trace"),
 			tests.Group("user_agent"),
 			tests.Group("destination"),
+			tests.Group("service.target"),
+			tests.Group("service.origin"),
 		),
 		[]tests.FieldTemplateMapping{
 			{Template: "agent.", Mapping: "service.agent."},
 			{Template: "container.", Mapping: "system.container."},
 			{Template: "kubernetes.", Mapping: "system.kubernetes."},
+			{Template: "host.os.full", Mapping: "system.platform_version"},
 			{Template: "host.os.platform", Mapping: "system.platform"},
 			{Template: "host.name", Mapping: "system.configured_hostname"},
 			{Template: "host.", Mapping: "system."},
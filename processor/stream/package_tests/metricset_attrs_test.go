@@ -58,9 +58,10 @@ func TestInvalidPayloads(t *testing.T) {
 	validMetric := obj{"value": json.Number("1.0")}
 	payloadData := []tests.SchemaTestData{
 		{Key: "metricset.timestamp",
-			Valid: val{json.Number("1496170422281000")},
+			Valid: val{json.Number("1496170422281000"), "-1500", "+20"},
 			Invalid: []tests.Invalid{
-				{Msg: `timestamp/type`, Values: val{"1496170422281000"}}}},
+				{Msg: `timestamp/type`, Values: val{true, 1.5}},
+				{Msg: `timestamp/pattern`, Values: val{"1496170422281000", "-1.5", "+"}}}},
 		{Key: "metricset.tags",
 			Valid: val{obj{tests.Str1024Special: tests.Str1024Special}, obj{tests.Str1024: 123.45}, obj{tests.Str1024: true}},
 			Invalid: []tests.Invalid{
@@ -72,6 +73,10 @@ func TestInvalidPayloads(t *testing.T) {
 			Key: "metricset.samples",
 			Valid: val{
 				obj{"valid-metric": validMetric},
+				obj{"string-metric": obj{"value": "9.16"}},
+				obj{"counter": obj{"value": json.Number("42"), "type": "counter"}},
+				obj{"summary": obj{"type": "summary", "sum": json.Number("12.5"), "count": json.Number("5")}},
+				obj{"histogram": obj{"values": val{json.Number("0.5"), json.Number("1.5")}, "counts": val{json.Number("3"), json.Number("0")}}},
 			},
 			Invalid: []tests.Invalid{
 				{
@@ -85,7 +90,13 @@ func TestInvalidPayloads(t *testing.T) {
 					Msg: "/properties/samples/patternproperties",
 					Values: val{
 						obj{"nil-value": obj{"value": nil}},
-						obj{"string-value": obj{"value": "foo"}},
+						obj{"bool-value": obj{"value": true}},
+						obj{"no-value": obj{}},
+						obj{"values-without-counts": obj{"values": val{json.Number("0.5")}}},
+						obj{"negative-count": obj{"values": val{json.Number("0.5")}, "counts": val{json.Number("-1")}}},
+						obj{"unknown-type": obj{"value": json.Number("1"), "type": "histogram"}},
+						obj{"summary-without-count": obj{"type": "summary", "sum": json.Number("12.5")}},
+						obj{"gauge-without-value": obj{"type": "gauge", "sum": json.Number("12.5"), "count": json.Number("5")}},
 					},
 				},
 			},
@@ -75,6 +75,9 @@ func spanFieldsNotInPayloadAttrs() *tests.Set {
 			tests.Group("transaction.self_time"),
 			tests.Group("transaction.breakdown"),
 			tests.Group("transaction.duration"),
+			// span links and OpenTelemetry span kinds are only decoded for transactions
+			tests.Group("span.links"),
+			"span.kind",
 			"experimental",
 		),
 		// not valid for the span context
@@ -150,6 +153,8 @@ func spanKeywordExceptionKeys() *tests.Set {
 		"processor.event", "processor.name",
 		"context.tags", "transaction.type", "transaction.name",
 		tests.Group("observer"),
+		tests.Group("span.links"),
+		"span.kind",
 
 		// metadata fields
 		tests.Group("agent"),
@@ -51,6 +51,8 @@ func transactionPayloadAttrsNotInFields() *tests.Set {
 		tests.Group("http.request.cookies"),
 		"transaction.message.body", "transaction.message.headers",
 		"http.response.decoded_body_size", "http.response.encoded_body_size", "http.response.transfer_size",
+		// defined by the libbeat ECS fields
+		"event.ingested",
 	)
 }
 
@@ -59,6 +61,7 @@ func transactionFieldsNotInPayloadAttrs() *tests.Set {
 		"context.http",
 		"context.http.status_code",
 		"host.ip",
+		"host.os.full",
 		"transaction.duration.count",
 		"transaction.marks.*.*",
 		"source.ip",
@@ -72,7 +75,25 @@ func transactionFieldsNotInPayloadAttrs() *tests.Set {
 		tests.Group("transaction.self_time"),
 		tests.Group("transaction.breakdown"),
 		tests.Group("transaction.duration.sum"),
+		tests.Group("service.target"),
+		tests.Group("service.origin"),
 		"experimental",
+		// derived from decode options not set for the payload
+		"transaction.category",
+		"transaction.duration.human",
+		"event.dropped",
+		// only emitted for transactions sent with a sample rate
+		"transaction.sample_rate",
+		"transaction.representative_count",
+		// only emitted for transactions sent with a tracestate
+		"transaction.tracestate",
+		"transaction.tracestate_truncated",
+		// only emitted for transactions with an overlong type
+		"transaction.type_truncated",
+		// only emitted for transactions sent with profiler stack trace IDs
+		"transaction.profiler_stack_trace_ids",
+		// faas is not part of the intake v2 spec
+		tests.Group("faas"),
 	)
 }
 
@@ -102,6 +123,12 @@ func transactionRequiredKeys() *tests.Set {
 		"transaction.type",
 		"transaction.context.request.method",
 		"transaction.context.request.url",
+		"transaction.dropped_spans_stats.destination_service_resource",
+		"transaction.dropped_spans_stats.outcome",
+		"transaction.dropped_spans_stats.duration",
+		"transaction.dropped_spans_stats.duration.count",
+		"transaction.dropped_spans_stats.duration.sum",
+		"transaction.dropped_spans_stats.duration.sum.us",
 	)
 }
 
@@ -109,7 +136,16 @@ func transactionKeywordExceptionKeys() *tests.Set {
 	return tests.NewSet(
 		"processor.event", "processor.name",
 		"transaction.marks",
+		"transaction.outcome",
+		"transaction.dropped_spans_stats.outcome",
+		"transaction.category",
+		"transaction.duration.human",
+		// limited to the W3C maximum length of 512 instead
+		"transaction.tracestate",
+		// array of IDs, limited in number instead
+		"transaction.profiler_stack_trace_ids",
 		"context.tags",
+		tests.Group("faas"),
 		tests.Group("observer"),
 		tests.Group("url"),
 		tests.Group("http"),
@@ -136,7 +172,9 @@ func TestTransactionPayloadMatchFields(t *testing.T) {
 func TestTransactionPayloadMatchJsonSchema(t *testing.T) {
 	transactionProcSetup().PayloadAttrsMatchJsonSchema(t,
 		transactionPayloadAttrsNotInJsonSchema(),
-		tests.NewSet("transaction.context.user.email", "transaction.context.experimental"))
+		tests.NewSet("transaction.context.user.email", "transaction.context.user.domain", "transaction.context.experimental",
+			// only in array tags
+			"transaction.context.tags.key", "transaction.context.tags.value"))
 }
 
 func TestAttrsPresenceInTransaction(t *testing.T) {
@@ -163,15 +201,19 @@ func TestPayloadDataForTransaction(t *testing.T) {
 	// * regex pattern, time formats
 	// * length restrictions, other than keyword length restrictions
 
-	transactionProcSetup().DataValidation(t,
+	setup := transactionProcSetup()
+	// decode the array tags that validate against the schema
+	setup.Proc.(*intakeTestProcessor).Mconfig.AcceptArrayTags = true
+	setup.DataValidation(t,
 		[]tests.SchemaTestData{
 			{Key: "transaction.duration",
 				Valid:   []interface{}{12.4},
 				Invalid: []tests.Invalid{{Msg: `duration/type`, Values: val{"123"}}}},
 			{Key: "transaction.timestamp",
-				Valid: val{json.Number("1496170422281000")},
+				Valid: val{json.Number("1496170422281000"), "-1500", "+20"},
 				Invalid: []tests.Invalid{
-					{Msg: `timestamp/type`, Values: val{"1496170422281000"}}}},
+					{Msg: `timestamp/type`, Values: val{true, 1.5}},
+					{Msg: `timestamp/pattern`, Values: val{"1496170422281000", "-1.5", "+"}}}},
 			{Key: "transaction.marks",
 				Valid: []interface{}{obj{}, obj{tests.Str1024: obj{tests.Str1024: 21.0, "end": -45}}},
 				Invalid: []tests.Invalid{
@@ -192,6 +234,9 @@ func TestPayloadDataForTransaction(t *testing.T) {
 				Invalid: []tests.Invalid{
 					{Msg: `context/properties/custom/additionalproperties`, Values: val{obj{"what.ever": 123}, obj{"what*ever": 123}, obj{"what\"ever": 123}}},
 					{Msg: `context/properties/custom/type`, Values: val{"context"}}}},
+			{Key: "transaction.context.message.queue",
+				Valid:   val{obj{"name": "orders"}, "orders"},
+				Invalid: []tests.Invalid{{Msg: `message/properties/queue/type`, Values: val{123}}}},
 			{Key: "transaction.context.request.body",
 				Valid:   []interface{}{obj{}, tests.Str1025},
 				Invalid: []tests.Invalid{{Msg: `context/properties/request/properties/body/type`, Values: val{102}}}},
@@ -212,11 +257,14 @@ func TestPayloadDataForTransaction(t *testing.T) {
 				obj{"foo": []interface{}{"a", "b"}}},
 				Invalid: []tests.Invalid{{Msg: `properties/headers`, Values: val{102, obj{"foo": obj{"bar": "a"}}}}}},
 			{Key: "transaction.context.tags",
-				Valid: val{obj{tests.Str1024Special: tests.Str1024Special}, obj{tests.Str1024: 123.45}, obj{tests.Str1024: true}},
+				Valid: val{obj{tests.Str1024Special: tests.Str1024Special}, obj{tests.Str1024: 123.45}, obj{tests.Str1024: true},
+					[]interface{}{obj{"key": "a", "value": "b"}, obj{"key": tests.Str1024, "value": 123.45}, obj{"key": "c", "value": nil}},
+					[]interface{}{obj{"key": "a"}}, []interface{}{obj{"key": "a.b", "value": "c"}}},
 				Invalid: []tests.Invalid{
-					{Msg: `tags/type`, Values: val{"tags"}},
-					{Msg: `tags/patternproperties`, Values: val{obj{"invalid": tests.Str1025}, obj{tests.Str1024: obj{}}}},
-					{Msg: `tags/additionalproperties`, Values: val{obj{"invali*d": "hello"}, obj{"invali\"d": "hello"}, obj{"invali.d": "hello"}}}}},
+					{Msg: `tags/anyof/0/type`, Values: val{"tags"}},
+					{Msg: `tags/anyof/0/patternproperties`, Values: val{obj{"invalid": tests.Str1025}, obj{tests.Str1024: obj{}}}},
+					{Msg: `tags/anyof/0/additionalproperties`, Values: val{obj{"invali*d": "hello"}, obj{"invali\"d": "hello"}, obj{"invali.d": "hello"}}},
+					{Msg: `tags/anyof/1/items/properties/key/maxlength`, Values: val{[]interface{}{obj{"key": tests.Str1025, "value": "c"}}}}}},
 			{Key: "transaction.context.user.id",
 				Valid: val{123, tests.Str1024Special},
 				Invalid: []tests.Invalid{
@@ -20,6 +20,7 @@ package stream
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"sync"
 	"time"
@@ -232,7 +233,7 @@ func (p *Processor) readBatch(
 
 	var out []transform.Transformable
 	for i := 0; i < batchSize && !reader.IsEOF(); i++ {
-		rawModel, err := reader.Read()
+		rawModel, err := reader.ReadEvent()
 		if err != nil && err != io.EOF {
 			if e, ok := err.(*Error); ok && (e.Type == InvalidInputErrType || e.Type == InputTooLargeErrType) {
 				response.LimitedAdd(e)
@@ -252,6 +253,10 @@ func (p *Processor) readBatch(
 				})
 				continue
 			}
+			if tr == nil {
+				// dropped by the decoder
+				continue
+			}
 			out = append(out, tr)
 		}
 	}
@@ -286,6 +291,8 @@ func (p *Processor) HandleStream(ctx context.Context, ipRateLimiter *rate.Limite
 		if len(transformables) == 0 {
 			continue
 		}
+		// estimate before reporting, which hands the events to the publisher
+		estimatedSize := estimateSize(transformables)
 		// NOTE(axw) `report` takes ownership of transformables, which
 		// means we cannot reuse the slice memory. We should investigate
 		// alternative interfaces between the processor and publisher
@@ -312,10 +319,27 @@ func (p *Processor) HandleStream(ctx context.Context, ipRateLimiter *rate.Limite
 			return res
 		}
 		res.AddAccepted(len(transformables))
+		res.EstimatedSize += estimatedSize
 	}
 	return res
 }
 
+// sizeEstimator is implemented by events able to cheaply estimate their
+// serialized size, such as transactions and metricsets.
+type sizeEstimator interface {
+	EstimatedSize() int
+}
+
+func estimateSize(transformables []transform.Transformable) int {
+	size := 0
+	for _, tr := range transformables {
+		if e, ok := tr.(sizeEstimator); ok {
+			size += e.EstimatedSize()
+		}
+	}
+	return size
+}
+
 // getStreamReader returns a streamReader that reads ND-JSON lines from r.
 func (p *Processor) getStreamReader(r io.Reader) *streamReader {
 	if sr, ok := p.streamReaderPool.Get().(*streamReader); ok {
@@ -362,6 +386,28 @@ func (sr *streamReader) Read() (map[string]interface{}, error) {
 				Document: string(sr.LatestLine()),
 			}
 		}
+		if err != io.EOF {
+			return v, err
+		}
+	}
+	return v, err
+}
+
+// ReadEvent reads the next event line, as Read does, additionally
+// rejecting lines larger than the processor's model.Config.MaxEventBytes.
+// The metadata line is not an event, and is read with Read.
+func (sr *streamReader) ReadEvent() (map[string]interface{}, error) {
+	v, err := sr.Read()
+	if err != nil && err != io.EOF {
+		return v, err
+	}
+	// check the size of the raw line, rather than re-encoding the decoded event
+	if max := sr.processor.Mconfig.MaxEventBytes; max > 0 && len(sr.LatestLine()) > max {
+		return nil, &Error{
+			Type:     InputTooLargeErrType,
+			Message:  fmt.Sprintf("event size of %d bytes exceeds maximum of %d bytes", len(sr.LatestLine()), max),
+			Document: string(sr.LatestLine()),
+		}
 	}
 	return v, err
 }
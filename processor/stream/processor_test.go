@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 	"testing/iotest"
 	"time"
@@ -99,7 +100,7 @@ func TestIntegrationESOutput(t *testing.T) {
 			events = append(events, transformable.Transform(ctx, p.Tcontext)...)
 		}
 		name := ctx.Value("name").(string)
-		verifyErr := approvals.ApproveEvents(events, name)
+		verifyErr := approvals.ApproveEvents(events, name, "ingested")
 		if verifyErr != nil {
 			assert.Fail(t, fmt.Sprintf("Test %s failed with error: %s", name, verifyErr.Error()))
 		}
@@ -107,13 +108,15 @@ func TestIntegrationESOutput(t *testing.T) {
 	}
 
 	for _, test := range []struct {
-		path string
-		name string
+		path      string
+		name      string
+		strictIDs bool
 	}{
 		{path: "errors.ndjson", name: "Errors"},
 		{path: "transactions.ndjson", name: "Transactions"},
 		{path: "spans.ndjson", name: "Spans"},
 		{path: "metricsets.ndjson", name: "Metricsets"},
+		{path: "metricsets-samples.ndjson", name: "MetricsetSamples"},
 		{path: "events.ndjson", name: "Events"},
 		{path: "minimal-service.ndjson", name: "MinimalService"},
 		{path: "metadata-null-values.ndjson", name: "MetadataNullValues"},
@@ -124,6 +127,7 @@ func TestIntegrationESOutput(t *testing.T) {
 		{path: "invalid-metadata-2.ndjson", name: "InvalidMetadata2"},
 		{path: "unrecognized-event.ndjson", name: "UnrecognizedEvent"},
 		{path: "optional-timestamps.ndjson", name: "OptionalTimestamps"},
+		{path: "transactions-strict-ids.ndjson", name: "TransactionsStrictIDs", strictIDs: true},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			b, err := loader.LoadDataAsBytes(filepath.Join("../testdata/intake-v2/", test.path))
@@ -142,6 +146,7 @@ func TestIntegrationESOutput(t *testing.T) {
 			}
 
 			p := BackendProcessor(&config.Config{MaxEventSize: 100 * 1024})
+			p.Mconfig.StrictIDs = test.strictIDs
 			actualResult := p.HandleStream(ctx, nil, reqDecoderMeta, bodyReader, report)
 			assertApproveResult(t, actualResult, test.name)
 		})
@@ -155,7 +160,7 @@ func TestIntegrationRum(t *testing.T) {
 			events = append(events, transformable.Transform(ctx, p.Tcontext)...)
 		}
 		name := ctx.Value("name").(string)
-		verifyErr := approvals.ApproveEvents(events, name)
+		verifyErr := approvals.ApproveEvents(events, name, "ingested")
 		if verifyErr != nil {
 			assert.Fail(t, fmt.Sprintf("Test %s failed with error: %s", name, verifyErr.Error()))
 		}
@@ -201,7 +206,7 @@ func TestRUMV3(t *testing.T) {
 			for _, transformable := range p.Transformables {
 				events = append(events, transformable.Transform(ctx, p.Tcontext)...)
 			}
-			verifyErr := approvals.ApproveEvents(events, name)
+			verifyErr := approvals.ApproveEvents(events, name, "ingested")
 			if verifyErr != nil {
 				assert.Fail(t, fmt.Sprintf("Test %s failed with error: %s", name, verifyErr.Error()))
 			}
@@ -267,3 +272,70 @@ func TestRateLimiting(t *testing.T) {
 		assertApproveResult(t, actualResult, test.name)
 	}
 }
+
+func TestEstimatedSize(t *testing.T) {
+	metadata := `{"metadata": {"service": {"name": "myservice", "agent": {"name": "go", "version": "1.0.0"}}}}`
+	transaction := `{"transaction": {"id": "0123456789abcdef", "trace_id": "0123456789abcdef0123456789abcdef", "type": "request", "duration": 1, "span_count": {"started": 0}}}`
+	errorEvent := `{"error": {"id": "0123456789abcdef", "log": {"message": "boom"}}}`
+
+	var reported []transform.Transformable
+	report := func(ctx context.Context, p publish.PendingReq) error {
+		reported = append(reported, p.Transformables...)
+		return nil
+	}
+	p := BackendProcessor(&config.Config{MaxEventSize: 100 * 1024})
+	body := bytes.NewBufferString(metadata + "\n" + transaction + "\n" + errorEvent + "\n")
+	result := p.HandleStream(context.Background(), nil, map[string]interface{}{}, body, report)
+	require.Empty(t, result.Errors)
+	require.Len(t, reported, 2)
+
+	// errors do not estimate their size, so only the transaction counts
+	tx, ok := reported[0].(sizeEstimator)
+	require.True(t, ok)
+	assert.NotZero(t, result.EstimatedSize)
+	assert.Equal(t, tx.EstimatedSize(), result.EstimatedSize)
+}
+
+func TestMaxEventBytes(t *testing.T) {
+	metadata := `{"metadata": {"service": {"name": "myservice", "agent": {"name": "go", "version": "1.0.0"}}}}`
+	transaction := `{"transaction": {"id": "0123456789abcdef", "trace_id": "0123456789abcdef0123456789abcdef", "type": "request", "duration": 1, "span_count": {"started": 0}}}`
+	report := func(ctx context.Context, p publish.PendingReq) error { return nil }
+
+	largeMetadata := `{"metadata": {"service": {"name": "myservice", "agent": {"name": "go", "version": "1.0.0"}}, "labels": {"padding": "` + strings.Repeat("x", len(transaction)) + `"}}}`
+
+	for name, test := range map[string]struct {
+		metadata string
+		max      int
+		accepted int
+		err      string
+	}{
+		"no limit":      {max: 0, accepted: 1},
+		"metadata over": {metadata: largeMetadata, max: len(transaction), accepted: 1},
+		"under":         {max: len(transaction) + 1, accepted: 1},
+		"at":            {max: len(transaction), accepted: 1},
+		"over": {
+			max: len(transaction) - 1,
+			err: fmt.Sprintf("event size of %d bytes exceeds maximum of %d bytes", len(transaction), len(transaction)-1),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := BackendProcessor(&config.Config{MaxEventSize: 100 * 1024})
+			p.Mconfig.MaxEventBytes = test.max
+			meta := metadata
+			if test.metadata != "" {
+				meta = test.metadata
+			}
+			body := bytes.NewBufferString(meta + "\n" + transaction + "\n")
+			result := p.HandleStream(context.Background(), nil, map[string]interface{}{}, body, report)
+			assert.Equal(t, test.accepted, result.Accepted)
+			if test.err == "" {
+				assert.Empty(t, result.Errors)
+				return
+			}
+			require.Len(t, result.Errors, 1)
+			assert.Equal(t, InputTooLargeErrType, result.Errors[0].Type)
+			assert.Equal(t, test.err, result.Errors[0].Message)
+			assert.Equal(t, transaction, result.Errors[0].Document)
+		})
+	}
+}
@@ -68,6 +68,10 @@ var (
 type Result struct {
 	Accepted int      `json:"accepted"`
 	Errors   []*Error `json:"errors,omitempty"`
+
+	// EstimatedSize holds the estimated serialized size in bytes of the
+	// accepted events able to estimate it. It is not reported to agents.
+	EstimatedSize int `json:"-"`
 }
 
 func (r *Result) LimitedAdd(err error) {
@@ -40,12 +40,13 @@ const ApprovedSuffix = ".approved.json"
 const ReceivedSuffix = ".received.json"
 
 // AssertApproveResult tests that given result equals an already approved result, and fails otherwise.
-func AssertApproveResult(t *testing.T, name string, actualResult []byte) {
+// Fields with any of the ignored names are not compared.
+func AssertApproveResult(t *testing.T, name string, actualResult []byte, ignored ...string) {
 	var resultmap map[string]interface{}
 	err := json.Unmarshal(actualResult, &resultmap)
 	require.NoError(t, err)
 
-	verifyErr := ApproveJSON(resultmap, name)
+	verifyErr := ApproveJSON(resultmap, name, ignored...)
 	if verifyErr != nil {
 		assert.Fail(t, fmt.Sprintf("Test %s failed with error: %s", name, verifyErr.Error()))
 	}
@@ -42,6 +42,11 @@ func IntPtr(i int) *int {
 	return &i
 }
 
+// Int64Ptr is a test helper function that returns the address of the given int64
+func Int64Ptr(i int64) *int64 {
+	return &i
+}
+
 func strConcat(pre string, post string, delimiter string) string {
 	if pre == "" {
 		return post
@@ -19,9 +19,13 @@ package transform
 
 import (
 	"context"
+	"net"
 	"regexp"
+	"sync"
+	"time"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
 
 	"github.com/elastic/apm-server/sourcemap"
 )
@@ -38,6 +42,37 @@ type Transformable interface {
 // non-RUM.
 type Context struct {
 	Config Config
+
+	// Now, if non-nil, is used in place of time.Now to obtain the
+	// time at which events are transformed, emitted as event.ingested.
+	Now func() time.Time
+
+	// MapStrPool, if non-nil, provides the maps used for building the
+	// fields of transformed events. See MapStrPool.Reset.
+	MapStrPool *MapStrPool
+
+	// GeoLookup, if non-nil, is used to look up the country ISO code and
+	// city name of client IPs, emitted under client.geo. It is only used
+	// for events decoded without a model.Config.GeoResolver.
+	GeoLookup func(net.IP) (country, city string)
+}
+
+// NewMapStr returns an empty common.MapStr for building event fields,
+// taken from c.MapStrPool if set.
+func (c *Context) NewMapStr() common.MapStr {
+	if c.MapStrPool != nil {
+		return c.MapStrPool.Get()
+	}
+	return common.MapStr{}
+}
+
+// IngestTime returns the time at which events are being transformed,
+// as reported by c.Now or time.Now.
+func (c *Context) IngestTime() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
 }
 
 type Config struct {
@@ -45,3 +80,45 @@ type Config struct {
 	ExcludeFromGrouping *regexp.Regexp
 	SourcemapStore      *sourcemap.Store
 }
+
+// MapStrPool is a sync.Pool-backed source of common.MapStr values, which
+// allows the maps of transformed events to be reused across events once
+// they have been published, reducing GC pressure.
+//
+// A MapStrPool is safe for concurrent use.
+type MapStrPool struct {
+	pool sync.Pool
+
+	mu   sync.Mutex
+	used []common.MapStr
+}
+
+// Get returns an empty common.MapStr. The map is considered in use until
+// the next call to Reset.
+func (p *MapStrPool) Get() common.MapStr {
+	m, ok := p.pool.Get().(common.MapStr)
+	if !ok {
+		m = common.MapStr{}
+	}
+	p.mu.Lock()
+	p.used = append(p.used, m)
+	p.mu.Unlock()
+	return m
+}
+
+// Reset clears all maps returned by Get since the last call to Reset, and
+// returns them to the pool. Reset must only be called once the events built
+// from the maps are no longer referenced, e.g. after their batch has been
+// published.
+func (p *MapStrPool) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, m := range p.used {
+		for k := range m {
+			delete(m, k)
+		}
+		p.pool.Put(m)
+		p.used[i] = nil
+	}
+	p.used = p.used[:0]
+}
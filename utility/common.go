@@ -18,6 +18,7 @@
 package utility
 
 import (
+	"math"
 	"net/url"
 	"path"
 )
@@ -68,3 +69,18 @@ func Contains(s string, a []string) bool {
 	}
 	return false
 }
+
+// Float64ToInt64 converts f to an int64, truncating towards zero. Values
+// out of the int64 range are clamped to its bounds rather than wrapping,
+// and NaN converts to 0.
+func Float64ToInt64(f float64) int64 {
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	case f <= math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(f)
+}
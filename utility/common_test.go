@@ -19,6 +19,7 @@ package utility
 
 import (
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -101,3 +102,26 @@ func TestInsertInMap(t *testing.T) {
 			fmt.Sprintf("At (%v): Expected %s, got %s", idx, test.result, test.data))
 	}
 }
+
+func TestFloat64ToInt64(t *testing.T) {
+	for name, test := range map[string]struct {
+		in  float64
+		out int64
+	}{
+		"zero":          {in: 0, out: 0},
+		"truncated":     {in: 1.9, out: 1},
+		"negative":      {in: -1.9, out: -1},
+		"largest exact": {in: 1 << 62, out: 1 << 62},
+		"max int64":     {in: math.MaxInt64, out: math.MaxInt64},
+		"max float":     {in: math.MaxFloat64, out: math.MaxInt64},
+		"+Inf":          {in: math.Inf(1), out: math.MaxInt64},
+		"min int64":     {in: math.MinInt64, out: math.MinInt64},
+		"lowest float":  {in: -math.MaxFloat64, out: math.MinInt64},
+		"-Inf":          {in: math.Inf(-1), out: math.MinInt64},
+		"NaN":           {in: math.NaN(), out: 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.out, Float64ToInt64(test.in))
+		})
+	}
+}
@@ -20,6 +20,7 @@ package utility
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/textproto"
@@ -31,25 +32,76 @@ import (
 
 type ManualDecoder struct {
 	Err error
+
+	// CollectErrors controls whether every field failure is recorded in
+	// Errs, in addition to setting Err.
+	CollectErrors bool
+	Errs          []error
 }
 
 var (
 	ErrFetch = errors.New("error fetching field")
 )
 
+// FieldError is a failure to decode the field at the dotted path Field.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Errors combines several decode failures into a single error.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the combined errors.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// fail records the failure to decode the field key nested under keys.
+func (d *ManualDecoder) fail(err error, key string, keys ...string) {
+	d.Err = err
+	if !d.CollectErrors {
+		return
+	}
+	field := strings.Join(append(keys[:len(keys):len(keys)], key), ".")
+	if n := len(d.Errs); n > 0 {
+		if last, ok := d.Errs[n-1].(*FieldError); ok && last.Field == field {
+			// e.g. String reporting the failure of StringPtr again
+			return
+		}
+	}
+	d.Errs = append(d.Errs, &FieldError{Field: field, Err: err})
+}
+
 func (d *ManualDecoder) Float64(base map[string]interface{}, key string, keys ...string) float64 {
 	val := getDeep(base, keys...)[key]
 	if valFloat, ok := val.(float64); ok {
 		return valFloat
 	} else if valNumber, ok := val.(json.Number); ok {
 		if valFloat, err := valNumber.Float64(); err != nil {
-			d.Err = err
+			d.fail(err, key, keys...)
 		} else {
 			return valFloat
 		}
 	}
 
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return 0.0
 }
 
@@ -61,13 +113,13 @@ func (d *ManualDecoder) Float64Ptr(base map[string]interface{}, key string, keys
 		return &valFloat
 	} else if valNumber, ok := val.(json.Number); ok {
 		if valFloat, err := valNumber.Float64(); err != nil {
-			d.Err = err
+			d.fail(err, key, keys...)
 		} else {
 			return &valFloat
 		}
 	}
 
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return nil
 }
 
@@ -77,7 +129,7 @@ func (d *ManualDecoder) IntPtr(base map[string]interface{}, key string, keys ...
 		return nil
 	} else if valNumber, ok := val.(json.Number); ok {
 		if valInt, err := valNumber.Int64(); err != nil {
-			d.Err = err
+			d.fail(err, key, keys...)
 		} else {
 			i := int(valInt)
 			return &i
@@ -93,7 +145,7 @@ func (d *ManualDecoder) IntPtr(base map[string]interface{}, key string, keys ...
 			return &valInt
 		}
 	}
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return nil
 }
 
@@ -103,7 +155,7 @@ func (d *ManualDecoder) Int64Ptr(base map[string]interface{}, key string, keys .
 		return nil
 	} else if valNumber, ok := val.(json.Number); ok {
 		if valInt, err := valNumber.Int64(); err != nil {
-			d.Err = err
+			d.fail(err, key, keys...)
 		} else {
 			i := int64(valInt)
 			return &i
@@ -114,7 +166,7 @@ func (d *ManualDecoder) Int64Ptr(base map[string]interface{}, key string, keys .
 			return &valInt
 		}
 	}
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return nil
 }
 
@@ -122,7 +174,7 @@ func (d *ManualDecoder) Int(base map[string]interface{}, key string, keys ...str
 	if val := d.IntPtr(base, key, keys...); val != nil {
 		return *val
 	}
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return 0
 }
 
@@ -134,7 +186,7 @@ func (d *ManualDecoder) StringPtr(base map[string]interface{}, key string, keys
 	if valStr, ok := val.(string); ok {
 		return &valStr
 	}
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return nil
 }
 
@@ -142,7 +194,7 @@ func (d *ManualDecoder) String(base map[string]interface{}, key string, keys ...
 	if val := d.StringPtr(base, key, keys...); val != nil {
 		return *val
 	}
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return ""
 }
 
@@ -156,7 +208,7 @@ func (d *ManualDecoder) NetIP(base map[string]interface{}, key string, keys ...s
 	if valStr, ok := val.(string); ok {
 		return ParseIP(valStr)
 	}
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return nil
 }
 
@@ -172,7 +224,7 @@ func (d *ManualDecoder) StringArr(base map[string]interface{}, key string, keys
 			if valStr, ok := v.(string); ok {
 				strArr[idx] = valStr
 			} else {
-				d.Err = ErrFetch
+				d.fail(ErrFetch, key, keys...)
 				return nil
 			}
 		}
@@ -181,7 +233,7 @@ func (d *ManualDecoder) StringArr(base map[string]interface{}, key string, keys
 	if strArr, ok := arr.([]string); ok {
 		return strArr
 	}
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return nil
 }
 
@@ -196,7 +248,7 @@ func (d *ManualDecoder) InterfaceArr(base map[string]interface{}, key string, ke
 	} else if valArr, ok := val.([]interface{}); ok {
 		return valArr
 	}
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return nil
 }
 
@@ -207,7 +259,7 @@ func (d *ManualDecoder) BoolPtr(base map[string]interface{}, key string, keys ..
 	} else if valBool, ok := val.(bool); ok {
 		return &valBool
 	}
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return nil
 }
 
@@ -218,7 +270,7 @@ func (d *ManualDecoder) MapStr(base map[string]interface{}, key string, keys ...
 	} else if valMapStr, ok := val.(map[string]interface{}); ok {
 		return valMapStr
 	}
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return nil
 }
 
@@ -232,7 +284,7 @@ func (d *ManualDecoder) TimeRFC3339(base map[string]interface{}, key string, key
 			return valTime
 		}
 	}
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return time.Time{}
 }
 
@@ -249,20 +301,37 @@ func (d *ManualDecoder) TimeEpochMicro(base map[string]interface{}, key string,
 			return time.Unix(sec, microsec*1000).UTC()
 		}
 	}
-	d.Err = ErrFetch
+	d.fail(ErrFetch, key, keys...)
 	return time.Time{}
 }
 
 func (d *ManualDecoder) Headers(base map[string]interface{}, fieldName string) http.Header {
+	return d.headers(base, fieldName, true)
+}
+
+// RawHeaders fetches the headers held in base[fieldName] like Headers,
+// but keeps the header names as given rather than canonicalizing them.
+func (d *ManualDecoder) RawHeaders(base map[string]interface{}, fieldName string) http.Header {
+	return d.headers(base, fieldName, false)
+}
+
+func (d *ManualDecoder) headers(base map[string]interface{}, fieldName string, canonicalize bool) http.Header {
 
 	h := d.MapStr(base, fieldName)
 	if d.Err != nil || len(h) == 0 {
 		return nil
 	}
 	httpHeader := http.Header{}
+	add := func(key, v string) {
+		if canonicalize {
+			httpHeader.Add(key, v)
+		} else {
+			httpHeader[key] = append(httpHeader[key], v)
+		}
+	}
 	for key, val := range h {
 		if v, ok := val.(string); ok {
-			httpHeader.Add(key, v)
+			add(key, v)
 			continue
 		}
 		vals := d.StringArr(h, key)
@@ -270,7 +339,7 @@ func (d *ManualDecoder) Headers(base map[string]interface{}, fieldName string) h
 			return nil
 		}
 		for _, v := range vals {
-			httpHeader.Add(key, v)
+			add(key, v)
 		}
 	}
 	return httpHeader
@@ -253,3 +253,26 @@ func TestTimeRFC3339(t *testing.T) {
 		assert.Equal(t, decoder.Err, test.err)
 	}
 }
+
+func TestCollectErrors(t *testing.T) {
+	decoder := ManualDecoder{CollectErrors: true}
+	decoder.String(decoderBase, "missing")
+	decoder.Float64(decoderBase, "str", "a", "b")
+	decoder.BoolPtr(decoderBase, "str")
+	decoder.StringPtr(decoderBase, "str")
+	assert.Equal(t, ErrFetch, decoder.Err)
+	assert.Equal(t, []error{
+		&FieldError{Field: "missing", Err: ErrFetch},
+		&FieldError{Field: "a.b.str", Err: ErrFetch},
+		&FieldError{Field: "str", Err: ErrFetch},
+	}, decoder.Errs)
+
+	err := Errors(decoder.Errs)
+	assert.EqualError(t, err, "missing: error fetching field; a.b.str: error fetching field; str: error fetching field")
+	assert.Len(t, err.Unwrap(), 3)
+
+	decoder = ManualDecoder{}
+	decoder.String(decoderBase, "missing")
+	assert.Equal(t, ErrFetch, decoder.Err)
+	assert.Nil(t, decoder.Errs)
+}
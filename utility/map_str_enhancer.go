@@ -186,7 +186,7 @@ func reverse(slice []string) {
 
 func MillisAsMicros(ms float64) common.MapStr {
 	m := common.MapStr{}
-	m["us"] = int(ms * 1000)
+	m["us"] = int(Float64ToInt64(ms * 1000))
 	return m
 }
 
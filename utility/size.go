@@ -0,0 +1,107 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package utility
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+// NumericFieldSize is the fixed size estimated for a numeric or boolean field.
+const NumericFieldSize = 8
+
+// EstimatedSize returns a cheap estimate of the serialized size in bytes of
+// the given values, without marshaling them: the length of their strings
+// and map keys, plus NumericFieldSize per numeric or boolean value. Nil
+// values, and values of unsupported types, are estimated at zero.
+func EstimatedSize(values ...interface{}) int {
+	size := 0
+	for _, v := range values {
+		size += estimatedSize(v)
+	}
+	return size
+}
+
+func estimatedSize(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(v)
+	case *string:
+		if v != nil {
+			return len(*v)
+		}
+	case []string:
+		size := 0
+		for _, s := range v {
+			size += len(s)
+		}
+		return size
+	case net.IP:
+		if v == nil {
+			return 0
+		}
+		if v.To4() != nil {
+			return len("255.255.255.255")
+		}
+		return len("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+	case http.Header:
+		size := 0
+		for k, values := range v {
+			size += len(k) + estimatedSize(values)
+		}
+		return size
+	case common.MapStr:
+		return estimatedSize(map[string]interface{}(v))
+	case map[string]interface{}:
+		size := 0
+		for k, value := range v {
+			size += len(k) + estimatedSize(value)
+		}
+		return size
+	case []interface{}:
+		size := 0
+		for _, value := range v {
+			size += estimatedSize(value)
+		}
+		return size
+	case *int:
+		if v != nil {
+			return NumericFieldSize
+		}
+	case *int64:
+		if v != nil {
+			return NumericFieldSize
+		}
+	case *float64:
+		if v != nil {
+			return NumericFieldSize
+		}
+	case *bool:
+		if v != nil {
+			return NumericFieldSize
+		}
+	case int, int64, float64, bool, json.Number:
+		return NumericFieldSize
+	}
+	return 0
+}
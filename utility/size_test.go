@@ -0,0 +1,51 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package utility
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimatedSize(t *testing.T) {
+	str := "abc"
+	var nilStr *string
+	for name, test := range map[string]struct {
+		values   []interface{}
+		expected int
+	}{
+		"none":        {},
+		"nil":         {values: []interface{}{nil, nilStr, net.IP(nil)}},
+		"strings":     {values: []interface{}{"ab", &str, []string{"a", "bc"}}, expected: 2 + 3 + 3},
+		"numbers":     {values: []interface{}{1, int64(2), 1.5, json.Number("3"), true}, expected: 5 * NumericFieldSize},
+		"ipv4":        {values: []interface{}{net.ParseIP("10.0.0.1")}, expected: 15},
+		"ipv6":        {values: []interface{}{net.ParseIP("::1")}, expected: 39},
+		"headers":     {values: []interface{}{http.Header{"Accept": {"a", "b"}}}, expected: 6 + 2},
+		"nested maps": {values: []interface{}{common.MapStr{"a": map[string]interface{}{"bc": []interface{}{"d", 1}}}}, expected: 1 + 2 + 1 + NumericFieldSize},
+		"unsupported": {values: []interface{}{struct{}{}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, EstimatedSize(test.values...))
+		})
+	}
+}